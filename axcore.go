@@ -32,10 +32,8 @@ func (p *AXCore) Run() {
 			} else {
 				log.Fatalf("Error: Accelerator is not in the set")
 			}
-			if multiPhaseReq.Current >= len(multiPhaseReq.Phases) {
-				log.Fatalf("Error: Accelerator cannot terminate a request")
-			}
-			// Forward to the outgoing queue
+			// Forward to the outgoing queue. A finished request is terminated
+			// by the processor it is forwarded to
 			outQueueIdx := p.forwardFunc(p.GetOutQueues(), multiPhaseReq)
 			// fmt.Println(p.GetOutQueues())
 			p.WriteOutQueueI(req, outQueueIdx)
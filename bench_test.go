@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// BenchmarkBackends compares the goroutine and the event loop backends on
+// the three phase topology (topology 5) with 64 GPCores and 32 AXCores at
+// 75% load
+func BenchmarkBackends(b *testing.B) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	for _, bb := range []struct {
+		name    string
+		backend engine.Backend
+	}{
+		{"goroutine", engine.GoroutineBackend},
+		{"loop", engine.EventLoopBackend},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			opts := simOptions{seed: 1, backend: bb.backend}
+			for i := 0; i < b.N; i++ {
+				multi_gpcore_multi_axcore_three_phase(10000, 1, 64, 32, 32, 4.8, 0.1, 0, 0.25, 0.5, 0.25,
+					forwardToOffloaderThreePhase, tryAxCoreOutqueueThenFallback, firstNonEmptyQueue, opts)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package blocks
 
 import (
 	"bufio"
-	"math/rand"
+	"fmt"
 	"os"
 	"strconv"
 )
@@ -13,42 +13,66 @@ type PBGenerator struct {
 	genericGenerator
 	sTimes   [][]int
 	cpuCount int
-	WaitTime randDist
 }
 
 // NewPBGenerator returns a PBGenerator
 // Parameters: lambda for the exponential interarrival and the filenames
-// with the service times
-func NewPBGenerator(lambda float64, paths []string) *PBGenerator {
+// with the service times, one integer per line
+func NewPBGenerator(lambda float64, paths []string) (*PBGenerator, error) {
 	g := PBGenerator{}
 
 	for _, p := range paths {
-		/* Read service times */
-		inFile, _ := os.Open(p)
-		defer inFile.Close()
-		scanner := bufio.NewScanner(inFile)
-		scanner.Split(bufio.ScanLines)
-
-		newTimes := make([]int, 0)
-		for scanner.Scan() {
-			n, _ := strconv.Atoi(scanner.Text())
-			newTimes = append(newTimes, n)
+		newTimes, err := readServiceTimes(p)
+		if err != nil {
+			return nil, err
 		}
 		g.sTimes = append(g.sTimes, newTimes)
 	}
 	g.cpuCount = len(paths)
-	g.WaitTime = newExponDistr(lambda)
-	return &g
+	g.WaitTime = NewExponDistr(lambda)
+	return &g, nil
+}
+
+// readServiceTimes reads the integer service times of a playback file
+func readServiceTimes(path string) ([]int, error) {
+	inFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+	scanner := bufio.NewScanner(inFile)
+	scanner.Split(bufio.ScanLines)
+
+	times := make([]int, 0)
+	for line := 1; scanner.Scan(); line++ {
+		n, err := strconv.Atoi(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		times = append(times, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("%v: no service times", path)
+	}
+	return times, nil
 }
 
-// Run is the main loop of the generator
+// Run is the main loop of the generator. It returns once the generator is
+// exhausted
 func (g *PBGenerator) Run() {
+	g.WaitTime.SetRand(g.NewRand())
 	for {
-		i := rand.Intn(g.cpuCount)
-		j := rand.Intn(len(g.sTimes[i]))
+		i := g.Rand().Intn(g.cpuCount)
+		j := g.Rand().Intn(len(g.sTimes[i]))
 		serviceTime := g.sTimes[i][j]
-		req := g.Creator.NewRequest(float64(serviceTime))
+		req := g.newRequest(float64(serviceTime))
 		g.WriteOutQueueI(req, i)
-		g.Wait(g.WaitTime.getRand())
+		if g.Exhausted() {
+			return
+		}
+		g.Wait(g.WaitTime.GetRand())
 	}
 }
@@ -0,0 +1,229 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// MMPPArrivals is a Markov modulated Poisson process used as an
+// interarrival time distribution. The process moves between states as a
+// continuous time Markov chain, and arrivals are Poisson with the rate of
+// the current state. Unlike the other distributions consecutive samples are
+// correlated.
+// Mean: 1 / sum of pi[i]*rates[i], where pi is the stationary distribution
+// of the chain
+type MMPPArrivals struct {
+	randSource
+	rates       []float64
+	transitions [][]float64
+	leave       []float64
+	state       int
+}
+
+// NewMMPPArrivals returns a new *MMPPArrivals with an arrival rate per
+// state. transitions[i][j] is the rate of moving from state i to state j;
+// the diagonal is ignored. The process starts in state 0
+func NewMMPPArrivals(rates []float64, transitions [][]float64) *MMPPArrivals {
+	m := &MMPPArrivals{rates: rates, transitions: transitions}
+	for i := range rates {
+		var q float64
+		for j, r := range transitions[i] {
+			if j != i {
+				q += r
+			}
+		}
+		m.leave = append(m.leave, q)
+	}
+	return m
+}
+
+func (m *MMPPArrivals) GetRand() float64 {
+	var t float64
+	for {
+		i := m.state
+		total := m.rates[i] + m.leave[i]
+		if total == 0 {
+			// absorbing state without arrivals
+			return math.Inf(1)
+		}
+		t += m.rng.ExpFloat64() / total
+		u := m.rng.Float64() * total
+		if u < m.rates[i] {
+			return t
+		}
+		u -= m.rates[i]
+		for j, r := range m.transitions[i] {
+			if j == i {
+				continue
+			}
+			if u < r {
+				m.state = j
+				break
+			}
+			u -= r
+		}
+	}
+}
+
+// Stationary returns the stationary distribution of the chain
+func (m *MMPPArrivals) Stationary() []float64 {
+	n := len(m.rates)
+	// solve pi Q = 0 with sum(pi) = 1 replacing the last equation
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+		for j := 0; j < n; j++ {
+			if i == n-1 {
+				a[i][j] = 1
+			} else if i == j {
+				a[i][j] = -m.leave[j]
+			} else {
+				a[i][j] = m.transitions[j][i]
+			}
+		}
+	}
+	a[n-1][n] = 1
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		a[c], a[p] = a[p], a[c]
+		for r := 0; r < n; r++ {
+			if r == c || a[c][c] == 0 {
+				continue
+			}
+			f := a[r][c] / a[c][c]
+			for k := c; k <= n; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+		}
+	}
+	pi := make([]float64, n)
+	for i := range pi {
+		pi[i] = a[i][n] / a[i][i]
+	}
+	return pi
+}
+
+func (m *MMPPArrivals) Mean() float64 {
+	var rate float64
+	for i, p := range m.Stationary() {
+		rate += p * m.rates[i]
+	}
+	return 1 / rate
+}
+
+// OnOffArrivals is an on/off source used as an interarrival time
+// distribution. Arrivals are Poisson with the given rate during on periods
+// and there are none during off periods. The period lengths are drawn from
+// their own distributions, which share the random stream of the source.
+// The source starts at the beginning of an on period.
+// Mean: (E[on]+E[off]) / (rate*E[on])
+type OnOffArrivals struct {
+	randSource
+	rate   float64
+	on     Distribution
+	off    Distribution
+	onLeft float64
+}
+
+// NewOnOffArrivals returns a new *OnOffArrivals
+func NewOnOffArrivals(rate float64, on, off Distribution) *OnOffArrivals {
+	return &OnOffArrivals{rate: rate, on: on, off: off, onLeft: -1}
+}
+
+// SetRand sets the random stream of the source and its periods
+func (o *OnOffArrivals) SetRand(r *rand.Rand) {
+	o.rng = r
+	o.on.SetRand(r)
+	o.off.SetRand(r)
+}
+
+func (o *OnOffArrivals) GetRand() float64 {
+	if o.onLeft < 0 {
+		o.onLeft = o.on.GetRand()
+	}
+	var t float64
+	for {
+		next := o.rng.ExpFloat64() / o.rate
+		if next <= o.onLeft {
+			o.onLeft -= next
+			return t + next
+		}
+		// skip the rest of the on period and the off period
+		t += o.onLeft + o.off.GetRand()
+		o.onLeft = o.on.GetRand()
+	}
+}
+
+func (o *OnOffArrivals) Mean() float64 {
+	on, off := o.on.Mean(), o.off.Mean()
+	return (on + off) / (o.rate * on)
+}
+
+// ArrivalMonitor is an engine.Observer that records the requests the
+// generators create in the measurement window. At the end it prints the
+// effective arrival rate and the index of dispersion of the counts in
+// intervals of the given length, which is 1 for Poisson arrivals. Register
+// it with both AddObserver and InitStats
+type ArrivalMonitor struct {
+	engine.NopObserver
+	window   engine.Window
+	interval float64
+	counts   []int
+	total    int
+}
+
+// NewArrivalMonitor returns a new *ArrivalMonitor counting arrivals in
+// intervals of the given length
+func NewArrivalMonitor(interval float64) *ArrivalMonitor {
+	return &ArrivalMonitor{interval: interval}
+}
+
+// SetWindow sets the measurement window
+func (m *ArrivalMonitor) SetWindow(w engine.Window) {
+	m.window = w
+}
+
+// Created counts a new request
+func (m *ArrivalMonitor) Created(now float64, actor int, req engine.ReqInterface) {
+	if !m.window.Contains(now) {
+		return
+	}
+	i := int((now - m.window.Start) / m.interval)
+	for len(m.counts) <= i {
+		m.counts = append(m.counts, 0)
+	}
+	m.counts[i]++
+	m.total++
+}
+
+// PrintStats prints the effective arrival rate and index of dispersion. The
+// dispersion is n/a without a complete interval with arrivals
+func (m *ArrivalMonitor) PrintStats(now float64) {
+	elapsed := m.window.Length(now)
+	// only complete intervals count for the dispersion
+	n := int(elapsed / m.interval)
+	for len(m.counts) < n {
+		m.counts = append(m.counts, 0)
+	}
+	var sum, sumSq float64
+	for _, c := range m.counts[:n] {
+		sum += float64(c)
+		sumSq += float64(c) * float64(c)
+	}
+	dispersion := "n/a"
+	if sum > 0 {
+		mean := sum / float64(n)
+		variance := sumSq/float64(n) - mean*mean
+		dispersion = fmt.Sprint(variance / mean)
+	}
+	fmt.Printf("Arrivals: %v\tRate: %v\tIndex of dispersion (%v): %v\n",
+		m.total, float64(m.total)/elapsed, m.interval, dispersion)
+}
@@ -1,8 +1,7 @@
 package blocks
 
 import (
-	"math/rand"
-	"time"
+	"math"
 
 	"github.com/neel-patel-1/xmp_sched_sim/engine"
 )
@@ -12,177 +11,298 @@ import (
 type Generator interface {
 	engine.ActorInterface
 	SetCreator(ReqCreator)
+	SetServiceTime(Distribution)
+	SetWaitTime(Distribution)
+}
+
+// requestSource keeps the source id of a generator, which its requests are
+// tagged with, and its request budget
+type requestSource struct {
+	id      int
+	budget  int
+	created int
+}
+
+// SetSource sets the id the requests of the generator are tagged with
+func (s *requestSource) SetSource(id int) {
+	s.id = id
+}
+
+// SetBudget makes the generator stop after creating n requests. Zero means
+// no limit
+func (s *requestSource) SetBudget(n int) {
+	s.budget = n
+}
+
+// Exhausted returns whether the generator created all the requests of its
+// budget
+func (s *requestSource) Exhausted() bool {
+	return s.budget > 0 && s.created >= s.budget
+}
+
+// tag accounts for a new request and tags it with the source id
+func (s *requestSource) tag(req engine.ReqInterface) {
+	s.created++
+	if tagged, ok := req.(SourceReq); ok {
+		tagged.SetSource(s.id)
+	}
 }
 
 type genericGenerator struct {
 	engine.Actor
+	requestSource
 	Creator     ReqCreator
-	ServiceTime randDist
-	WaitTime    randDist
+	ServiceTime Distribution
+	WaitTime    Distribution
 }
 
 func (g *genericGenerator) SetCreator(rc ReqCreator) {
 	g.Creator = rc
 }
 
-type randGenerator struct {
+// SetServiceTime sets the distribution of the request service times
+func (g *genericGenerator) SetServiceTime(d Distribution) {
+	g.ServiceTime = d
+}
+
+// SetWaitTime sets the distribution of the interarrival times
+func (g *genericGenerator) SetWaitTime(d Distribution) {
+	g.WaitTime = d
+}
+
+// IsGenerator marks the actor as a request source for the simulation
+func (g *genericGenerator) IsGenerator() bool {
+	return true
+}
+
+// newRequest creates a request arriving now and reports it to the
+// simulation
+func (g *genericGenerator) newRequest(serviceTime float64) engine.ReqInterface {
+	req := g.Creator.NewRequest(g.GetTime(), serviceTime)
+	g.tag(req)
+	g.ReportCreation(req)
+	return req
+}
+
+// initRand gives each distribution of the generator its own random stream,
+// and the request creator its streams if it draws its own.
+// It is called at the beginning of Run, after the actor is registered
+func (g *genericGenerator) initRand() {
+	g.ServiceTime.SetRand(g.NewRand())
+	g.WaitTime.SetRand(g.NewRand())
+	if rc, ok := g.Creator.(RandReqCreator); ok {
+		rc.InitRand(g.NewRand)
+	}
+}
+
+// ComposedGenerator draws interarrival and service times from any
+// distribution and picks the out queue of each request with a
+// DispatchPolicy. With a batch size distribution every arrival is a batch
+// of requests arriving at the same instant
+type ComposedGenerator struct {
 	genericGenerator
+	Dispatch DispatchPolicy
+	batch    Distribution
+	spread   bool
+}
+
+// NewComposedGenerator returns a ComposedGenerator
+func NewComposedGenerator(waitTime, serviceTime Distribution, dispatch DispatchPolicy) *ComposedGenerator {
+	g := &ComposedGenerator{Dispatch: dispatch}
+	g.ServiceTime = serviceTime
+	g.WaitTime = waitTime
+	return g
 }
 
-func (g *randGenerator) Run() {
+// SetDispatch sets the out queue selection policy
+func (g *ComposedGenerator) SetDispatch(d DispatchPolicy) {
+	g.Dispatch = d
+}
+
+// CheckDispatch returns an error if the dispatch policy cannot feed the out
+// queues of the generator. It should be called once the out queues are
+// added
+func (g *ComposedGenerator) CheckDispatch() error {
+	return checkDispatch(g.Dispatch, g.GetOutQueueCount())
+}
+
+// SetBatch makes every arrival a batch of requests, whose size is drawn
+// from size rounded up. If spread is false the whole batch goes to the out
+// queue the policy selects for its first request, otherwise the policy
+// selects the out queue of every request
+func (g *ComposedGenerator) SetBatch(size Distribution, spread bool) {
+	g.batch = size
+	g.spread = spread
+}
+
+// Run is the main loop of the generator. It returns once the generator is
+// exhausted
+func (g *ComposedGenerator) Run() {
+	g.initRand()
+	g.initDispatch()
 	for {
-		req := g.Creator.NewRequest(g.ServiceTime.getRand())
-		qIdx := rand.Intn(g.GetOutQueueCount())
-		if monitorReq, ok := req.(*MonitorReq); ok {
-			monitorReq.initLength = g.GetAllOutQueueLens()[qIdx]
+		g.arrive(nil)
+		if g.Exhausted() {
+			return
 		}
-		g.WriteOutQueueI(req, qIdx)
-		g.Wait(g.WaitTime.getRand())
+		g.Wait(g.WaitTime.GetRand())
 	}
 }
 
-type rRGenerator struct {
-	genericGenerator
+// initDispatch checks the dispatch policy against the out queues and gives
+// it the generator stream
+func (g *ComposedGenerator) initDispatch() {
+	if err := g.CheckDispatch(); err != nil {
+		panic(err)
+	}
+	g.Dispatch.SetRand(g.Rand())
 }
 
-func (g *rRGenerator) Run() {
-	for count := 0; ; count++ {
-		req := g.Creator.NewRequest(g.ServiceTime.getRand())
-		g.WriteOutQueueI(req, count%g.GetOutQueueCount())
-		g.Wait(g.WaitTime.getRand())
+// initRand also gives the batch size distribution its own stream
+func (g *ComposedGenerator) initRand() {
+	g.genericGenerator.initRand()
+	if g.batch != nil {
+		g.batch.SetRand(g.NewRand())
 	}
 }
 
+// batchSize returns the number of requests of the next arrival
+func (g *ComposedGenerator) batchSize() int {
+	if g.batch == nil {
+		return 1
+	}
+	n := int(math.Ceil(g.batch.GetRand()))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// arrive creates and dispatches the requests of an arrival, as many as the
+// budget allows. If not nil, init is called on every request before it is
+// dispatched
+func (g *ComposedGenerator) arrive(init func(engine.ReqInterface)) {
+	qIdx := -1
+	for i, n := 0, g.batchSize(); i < n && !g.Exhausted(); i++ {
+		req := g.newRequest(g.ServiceTime.GetRand())
+		if init != nil {
+			init(req)
+		}
+		if qIdx < 0 || g.spread {
+			qIdx = g.Dispatch.Select(g.GetOutQueues(), req, g.GetTime())
+		}
+		g.writeOut(req, qIdx)
+	}
+}
+
+// dispatch writes the request to the out queue the policy selects
+func (g *ComposedGenerator) dispatch(req engine.ReqInterface) {
+	g.writeOut(req, g.Dispatch.Select(g.GetOutQueues(), req, g.GetTime()))
+}
+
+func (g *ComposedGenerator) writeOut(req engine.ReqInterface, qIdx int) {
+	if monitorReq, ok := req.(*MonitorReq); ok {
+		monitorReq.initLength = g.GetAllOutQueueLens()[qIdx]
+	}
+	g.WriteOutQueueI(req, qIdx)
+}
+
+// RandGenerator draws interarrival and service times from any distribution
+// and feeds its out queues randomly
+type RandGenerator struct {
+	ComposedGenerator
+}
+
+// NewRandGenerator returns a RandGenerator
+func NewRandGenerator(waitTime, serviceTime Distribution) *RandGenerator {
+	return &RandGenerator{*NewComposedGenerator(waitTime, serviceTime, &RandomDispatch{})}
+}
+
 // DDGenerator is a fixed waiting time generator that produces fixed service time requests
 type DDGenerator struct {
-	rRGenerator
+	ComposedGenerator
 }
 
 // NewDDGenerator returns a DDGenerator
 func NewDDGenerator(waitTime, serviceTime float64) *DDGenerator {
-	g := &DDGenerator{}
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	g.WaitTime = newDeterministicDistr(waitTime)
-	return g
+	return &DDGenerator{*NewComposedGenerator(NewDeterministicDistr(waitTime), NewDeterministicDistr(serviceTime), &RoundRobinDispatch{})}
 }
 
 // MDGenerator is a exponential waiting time generator that produces fixed service time requests
 // If multiple queues they are fed round robin
 type MDGenerator struct {
-	rRGenerator
+	ComposedGenerator
 }
 
 // NewMDGenerator returns a MDGenerator
 func NewMDGenerator(waitLambda float64, serviceTime float64) *MDGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MDGenerator{}
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MDGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewDeterministicDistr(serviceTime), &RoundRobinDispatch{})}
 }
 
 // MDRandGenerator is a exponential waiting time generator that produces fixed service time requests
 // If multiple queues they are fed randomly
 type MDRandGenerator struct {
-	randGenerator
+	ComposedGenerator
 }
 
 // NewMDRandGenerator returns a MDRandGenerator
 func NewMDRandGenerator(waitLambda float64, serviceTime float64) *MDRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MDRandGenerator{}
-	g.WaitTime = newExponDistr(waitLambda)
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	return g
+	return &MDRandGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewDeterministicDistr(serviceTime), &RandomDispatch{})}
 }
 
 // MMGenerator is a exponential waiting time generator that produces exponential service time requests
 // If multiple queues they are fed round robin
 type MMGenerator struct {
-	rRGenerator
+	ComposedGenerator
 }
 
 // NewMMGenerator returns a MMGenerator
 func NewMMGenerator(waitLambda float64, serviceMu float64) *MMGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MMGenerator{}
-	g.ServiceTime = newExponDistr(serviceMu)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MMGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewExponDistr(serviceMu), &RoundRobinDispatch{})}
 }
 
 // MMRandGenerator is a exponential waiting time generator that produces exponential service time requests
 // If multiple queues they are fed randomly
 type MMRandGenerator struct {
-	randGenerator
+	ComposedGenerator
 }
 
 // NewMMRandGenerator returns a MMRandGenerator
 func NewMMRandGenerator(waitLambda float64, serviceMu float64) *MMRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MMRandGenerator{}
-	g.ServiceTime = newExponDistr(serviceMu)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MMRandGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewExponDistr(serviceMu), &RandomDispatch{})}
 }
 
 // MLNGenerator is exponential waiting time lognormal service time generator
 // If multiple queues they are fed round robin
 type MLNGenerator struct {
-	rRGenerator
+	ComposedGenerator
 }
 
 // NewMLNGenerator returns an MLNGenerator
 func NewMLNGenerator(waitLambda, mu, sigma float64) *MLNGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MLNGenerator{}
-	g.ServiceTime = newLGDistr(mu, sigma)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MLNGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewLGDistr(mu, sigma), &RoundRobinDispatch{})}
 }
 
 // MBGenerator is a poisson interarrival generator with
 // requests with bimodal service times (2 values)
 // If multiple queues they are fed roundrobin
 type MBGenerator struct {
-	rRGenerator
+	ComposedGenerator
 }
 
 // NewMBGenerator returns a MBGenerator
 func NewMBGenerator(waitLambda, peak1, peak2, ratio float64) *MBGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MBGenerator{}
-	g.ServiceTime = newBiDistr(peak1, peak2, ratio)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MBGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewBiDistr(peak1, peak2, ratio), &RoundRobinDispatch{})}
 }
 
 // MBRandGenerator is a poisson interarrival generator with
 // requests with bimodal service times (2 values)
 // If multiple queues they are fed randomly
 type MBRandGenerator struct {
-	randGenerator
+	ComposedGenerator
 }
 
 // NewMBRandGenerator returns a new MBRandGenerator
 func NewMBRandGenerator(waitLambda, peak1, peak2, ratio float64) *MBRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MBRandGenerator{}
-	g.ServiceTime = newBiDistr(peak1, peak2, ratio)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+	return &MBRandGenerator{*NewComposedGenerator(NewExponDistr(waitLambda), NewBiDistr(peak1, peak2, ratio), &RandomDispatch{})}
 }
@@ -0,0 +1,147 @@
+package blocks_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// runBatches runs a generator with arrivals every 10 that feeds two
+// instant cores round robin, and returns its writes
+func runBatches(t *testing.T, batch string, spread bool, budget int) (*writes, []engine.QueueInterface) {
+	t.Helper()
+	sim := engine.NewSimulation(1)
+	obs := &writes{}
+	sim.AddObserver(obs)
+	g := blocks.NewComposedGenerator(blocks.NewDeterministicDistr(10), blocks.NewDeterministicDistr(0), &blocks.RoundRobinDispatch{})
+	g.SetCreator(&blocks.SimpleReqCreator{})
+	g.SetBudget(budget)
+	if batch != "" {
+		size, err := blocks.ParseDistribution(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.SetBatch(size, spread)
+	}
+	var queues []engine.QueueInterface
+	for i := 0; i < 2; i++ {
+		q := blocks.NewQueue()
+		g.AddOutQueue(q)
+		p := &blocks.RTCProcessor{}
+		p.AddInQueue(q)
+		p.SetReqDrain(&counter{})
+		sim.RegisterActor(p)
+		queues = append(queues, q)
+	}
+	sim.RegisterActor(g)
+	if err := sim.Run(1e9, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	return obs, queues
+}
+
+func TestBatchArrivals(t *testing.T) {
+	tests := []struct {
+		name   string
+		batch  string
+		spread bool
+		budget int
+		times  []float64
+		queues []int
+	}{
+		{"no batch", "", false, 3, []float64{0, 10, 20}, []int{0, 1, 0}},
+		{"one queue", "det:3", false, 6, []float64{0, 0, 0, 10, 10, 10}, []int{0, 0, 0, 1, 1, 1}},
+		{"spread", "det:3", true, 6, []float64{0, 0, 0, 10, 10, 10}, []int{0, 1, 0, 1, 0, 1}},
+		{"rounded up", "det:2.5", false, 6, []float64{0, 0, 0, 10, 10, 10}, []int{0, 0, 0, 1, 1, 1}},
+		{"at least one", "det:0", false, 2, []float64{0, 10}, []int{0, 1}},
+		{"budget cuts the batch", "det:3", false, 7, []float64{0, 0, 0, 10, 10, 10, 20}, []int{0, 0, 0, 1, 1, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obs, queues := runBatches(t, tt.batch, tt.spread, tt.budget)
+			var idxs []int
+			for _, q := range obs.queues {
+				for i := range queues {
+					if queues[i] == q {
+						idxs = append(idxs, i)
+					}
+				}
+			}
+			if !reflect.DeepEqual(obs.times, tt.times) || !reflect.DeepEqual(idxs, tt.queues) {
+				t.Errorf("writes at %v to %v, want at %v to %v", obs.times, idxs, tt.times, tt.queues)
+			}
+		})
+	}
+}
+
+func TestBatchSizes(t *testing.T) {
+	// batch sizes are uniform on 1 to 4 once rounded up, 2.5 on average
+	const n = 100000
+	obs, _ := runBatches(t, "uniform:0,4", false, n)
+	batches := 0
+	for i, at := range obs.times {
+		if i == 0 || at != obs.times[i-1] {
+			batches++
+		}
+	}
+	if got := float64(n) / float64(batches); math.Abs(got-2.5) > 0.05 {
+		t.Errorf("mean batch size %v, want 2.5", got)
+	}
+}
+
+// bySource counts the finished requests of every source
+type bySource map[int]int
+
+func (s bySource) TerminateReq(req engine.ReqInterface, now float64) {
+	s[req.(blocks.SourceReq).GetSource()]++
+}
+
+func (s bySource) SetName(name string) {}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budgets []int
+	}{
+		{"one generator", []int{100}},
+		{"two generators", []int{30, 50}},
+		{"three generators", []int{1, 20, 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := engine.NewSimulation(1)
+			done := bySource{}
+			q := blocks.NewQueue()
+			for i := 0; i < 2; i++ {
+				p := &blocks.RTCProcessor{}
+				p.AddInQueue(q)
+				p.SetReqDrain(done)
+				sim.RegisterActor(p)
+			}
+			want := bySource{}
+			for i, budget := range tt.budgets {
+				g := blocks.NewMMRandGenerator(0.5, 1)
+				g.SetCreator(&blocks.SimpleReqCreator{})
+				g.SetSource(i + 1)
+				g.SetBudget(budget)
+				g.AddOutQueue(q)
+				sim.RegisterActor(g)
+				want[i+1] = budget
+			}
+			// the run ends once the generators are exhausted and every
+			// request is finished, long before the threshold
+			if err := sim.Run(1e9, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(done, want) {
+				t.Errorf("finished %v by source, want %v", done, want)
+			}
+			if sim.GetTime() >= 1e6 || sim.InFlight() != 0 {
+				t.Errorf("ended at %v with %v in flight, want before 1e6 with none", sim.GetTime(), sim.InFlight())
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package blocks
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// thinkEvent is a client that will issue its next request at time
+type thinkEvent struct {
+	time   float64
+	client int
+}
+
+type thinkQueue []thinkEvent
+
+func (q thinkQueue) Len() int { return len(q) }
+func (q thinkQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time < q[j].time
+	}
+	return q[i].client < q[j].client
+}
+func (q thinkQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *thinkQueue) Push(x interface{}) { *q = append(*q, x.(thinkEvent)) }
+func (q *thinkQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// ClosedLoopGenerator models a fixed population of clients. Every client
+// issues a request, waits for it to finish, thinks for a time drawn from
+// the think time distribution and issues the next one. The generator is the
+// RequestDrain of the processors: it hands finished requests to its own
+// drain and signals its Run, which puts their clients to think. It is also an engine.Stats, so register it
+// with InitStats to get the throughput and the response time by Little's
+// law at the end
+type ClosedLoopGenerator struct {
+	ComposedGenerator
+	windowFilter
+	clients  int
+	drain    RequestDrain
+	finished []engine.ReqInterface
+	signal   engine.Signal
+	owner    map[engine.ReqInterface]int
+	done     int
+	delay    float64
+}
+
+// NewClosedLoopGenerator returns a ClosedLoopGenerator with the given
+// number of clients
+func NewClosedLoopGenerator(clients int, thinkTime, serviceTime Distribution, dispatch DispatchPolicy) *ClosedLoopGenerator {
+	g := &ClosedLoopGenerator{
+		clients: clients,
+		owner:   make(map[engine.ReqInterface]int),
+	}
+	g.ServiceTime = serviceTime
+	g.WaitTime = thinkTime
+	g.Dispatch = dispatch
+	return g
+}
+
+// SetReqDrain sets the drain finished requests are handed to
+func (g *ClosedLoopGenerator) SetReqDrain(rd RequestDrain) {
+	g.drain = rd
+}
+
+// SetName does nothing. It makes the generator a RequestDrain
+func (g *ClosedLoopGenerator) SetName(name string) {}
+
+// TerminateReq hands the request to the drain and signals the generator.
+// It runs on behalf of the processor that finished the request, so it
+// leaves the generator queues alone
+func (g *ClosedLoopGenerator) TerminateReq(req engine.ReqInterface, now float64) {
+	if g.drain != nil {
+		g.drain.TerminateReq(req, now)
+	}
+	if g.inWindow(req, now) {
+		g.done++
+		g.delay += req.GetDelay(now)
+	}
+	g.finished = append(g.finished, req)
+	g.signal.Notify()
+}
+
+// issue sends the next request of a client, unless the generator is
+// exhausted
+func (g *ClosedLoopGenerator) issue(client int) {
+	if g.Exhausted() {
+		return
+	}
+	req := g.newRequest(g.ServiceTime.GetRand())
+	g.owner[req] = client
+	g.dispatch(req)
+}
+
+// Run is the main loop of the generator. Once the generator is exhausted it
+// returns when the last request comes back
+func (g *ClosedLoopGenerator) Run() {
+	g.initRand()
+	g.initDispatch()
+	thinking := &thinkQueue{}
+	for c := 0; c < g.clients; c++ {
+		g.issue(c)
+	}
+	for !g.Exhausted() || len(g.owner) > 0 {
+		d := -1.0
+		if thinking.Len() > 0 {
+			d = (*thinking)[0].time - g.GetTime()
+			if d <= 0 {
+				g.issue(heap.Pop(thinking).(thinkEvent).client)
+				continue
+			}
+		}
+		if !g.WaitSignal(&g.signal, d) {
+			continue
+		}
+		for _, req := range g.finished {
+			client := g.owner[req]
+			delete(g.owner, req)
+			heap.Push(thinking, thinkEvent{time: g.GetTime() + g.WaitTime.GetRand(), client: client})
+		}
+		g.finished = g.finished[:0]
+	}
+}
+
+// PrintStats prints the throughput and the mean response time, measured
+// and by Little's law: N/X - Z for N clients, throughput X and mean think
+// time Z
+func (g *ClosedLoopGenerator) PrintStats(now float64) {
+	x := float64(g.done) / g.window.Length(now)
+	fmt.Printf("Clients\tThroughput\tResponse\tLittle response\tThink\n")
+	fmt.Printf("%v\t%v\t%v\t%v\t%v\n", g.clients, x, g.delay/float64(g.done),
+		float64(g.clients)/x-g.WaitTime.Mean(), g.WaitTime.Mean())
+}
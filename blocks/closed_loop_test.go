@@ -0,0 +1,67 @@
+package blocks_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// writes records the time, the actor and the queue of every enqueue
+type writes struct {
+	engine.NopObserver
+	times  []float64
+	actors []int
+	queues []engine.QueueInterface
+}
+
+func (w *writes) Enqueued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	w.times = append(w.times, now)
+	w.actors = append(w.actors, actor)
+	w.queues = append(w.queues, q)
+}
+
+func TestClosedLoopGenerator(t *testing.T) {
+	for _, b := range []struct {
+		name    string
+		backend engine.Backend
+	}{
+		{"goroutine", engine.GoroutineBackend},
+		{"loop", engine.EventLoopBackend},
+	} {
+		sim := engine.NewSimulation(1)
+		sim.SetBackend(b.backend)
+		obs := &writes{}
+		sim.AddObserver(obs)
+		// 2 clients thinking for 1 share a core serving for 2
+		g := blocks.NewClosedLoopGenerator(2, blocks.NewDeterministicDistr(1), blocks.NewDeterministicDistr(2), &blocks.RoundRobinDispatch{})
+		g.SetCreator(&blocks.SimpleReqCreator{})
+		g.SetBudget(6)
+		done := &counter{}
+		g.SetReqDrain(done)
+		q := blocks.NewQueue()
+		g.AddOutQueue(q)
+		p := &blocks.RTCProcessor{}
+		p.AddInQueue(q)
+		p.SetReqDrain(g)
+		sim.RegisterActor(p)
+		sim.RegisterActor(g)
+		if err := sim.Run(1000, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		if done.n != 6 || sim.GetTime() != 12 {
+			t.Errorf("%v backend: finished %v requests at %v, want 6 at 12", b.name, done.n, sim.GetTime())
+		}
+		// only the generator writes, to its out queue, when a client issues
+		// a request
+		if want := []float64{0, 0, 3, 5, 7, 9}; !reflect.DeepEqual(obs.times, want) {
+			t.Errorf("%v backend: writes at %v, want %v", b.name, obs.times, want)
+		}
+		for i := range obs.actors {
+			if obs.actors[i] != g.GetID() || obs.queues[i] != q {
+				t.Errorf("%v backend: write %v by actor %v to %v, want the generator to its out queue", b.name, i, obs.actors[i], obs.queues[i])
+			}
+		}
+	}
+}
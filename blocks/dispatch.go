@@ -0,0 +1,276 @@
+package blocks
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// DispatchPolicy picks the out queue a generator writes each request to.
+// SetRand gives the policy the random stream of the generator
+type DispatchPolicy interface {
+	SetRand(r *rand.Rand)
+	Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int
+}
+
+// fixedQueues is implemented by the dispatch policies that can only feed a
+// given number of out queues
+type fixedQueues interface {
+	Queues() int
+}
+
+// checkDispatch returns an error if the policy cannot feed n out queues
+func checkDispatch(d DispatchPolicy, n int) error {
+	if n == 0 {
+		return fmt.Errorf("dispatch: no out queues")
+	}
+	if f, ok := d.(fixedQueues); ok && f.Queues() != n {
+		return fmt.Errorf("dispatch: policy feeds %v out queues, the generator has %v", f.Queues(), n)
+	}
+	return nil
+}
+
+// RoundRobinDispatch feeds the queues round robin
+type RoundRobinDispatch struct {
+	randSource
+	next int
+}
+
+// Select returns the next queue in turn
+func (d *RoundRobinDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	idx := d.next % len(queues)
+	d.next++
+	return idx
+}
+
+// RandomDispatch feeds the queues uniformly at random
+type RandomDispatch struct {
+	randSource
+}
+
+// Select returns a random queue
+func (d *RandomDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	return d.rng.Intn(len(queues))
+}
+
+// WorkQueue is a queue that knows the service time of the requests it
+// holds
+type WorkQueue interface {
+	engine.QueueInterface
+	Work() float64
+}
+
+// queueView is the queue information a dispatch policy sees. If stale is
+// positive it is a snapshot refreshed every stale time units, otherwise it
+// is up to date
+type queueView struct {
+	stale   float64
+	updated float64
+	valid   bool
+	lens    []int
+	work    []float64
+}
+
+func (v *queueView) refresh(queues []engine.QueueInterface, now float64, withWork bool) {
+	if v.valid && v.stale > 0 && now-v.updated < v.stale {
+		return
+	}
+	v.valid, v.updated = true, now
+	v.lens = v.lens[:0]
+	v.work = v.work[:0]
+	for _, q := range queues {
+		v.lens = append(v.lens, q.Len())
+		if !withWork {
+			continue
+		}
+		// queues that do not know their work count a unit per request
+		w := float64(q.Len())
+		if wq, ok := q.(WorkQueue); ok {
+			w = wq.Work()
+		}
+		v.work = append(v.work, w)
+	}
+}
+
+// pickMin returns the candidate with the lowest load, breaking ties at
+// random
+func pickMin(rng *rand.Rand, candidates []int, load func(i int) float64) int {
+	best, ties := -1, 0
+	for _, i := range candidates {
+		l := load(i)
+		switch {
+		case best == -1 || l < load(best):
+			best, ties = i, 1
+		case l == load(best):
+			ties++
+			if rng.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// JSQDispatch joins the shortest queue
+type JSQDispatch struct {
+	randSource
+	view queueView
+	all  []int
+}
+
+// NewJSQDispatch returns a new *JSQDispatch that sees queue lengths up to
+// stale time units old
+func NewJSQDispatch(stale float64) *JSQDispatch {
+	return &JSQDispatch{view: queueView{stale: stale}}
+}
+
+// Select returns the shortest queue
+func (d *JSQDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	d.view.refresh(queues, now, false)
+	for len(d.all) < len(queues) {
+		d.all = append(d.all, len(d.all))
+	}
+	return pickMin(d.rng, d.all[:len(queues)], func(i int) float64 { return float64(d.view.lens[i]) })
+}
+
+// PowerOfDDispatch samples d queues at random and joins the shortest
+type PowerOfDDispatch struct {
+	randSource
+	d    int
+	view queueView
+	idx  []int
+}
+
+// NewPowerOfDDispatch returns a new *PowerOfDDispatch that sees queue
+// lengths up to stale time units old
+func NewPowerOfDDispatch(d int, stale float64) *PowerOfDDispatch {
+	return &PowerOfDDispatch{d: d, view: queueView{stale: stale}}
+}
+
+// Select returns the shortest of d random queues
+func (d *PowerOfDDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	d.view.refresh(queues, now, false)
+	n := len(queues)
+	for len(d.idx) < n {
+		d.idx = append(d.idx, len(d.idx))
+	}
+	idx := d.idx[:n]
+	k := min(d.d, n)
+	// partial Fisher-Yates shuffle for k distinct queues
+	for i := 0; i < k; i++ {
+		j := i + d.rng.Intn(n-i)
+		idx[i], idx[j] = idx[j], idx[i]
+	}
+	return pickMin(d.rng, idx[:k], func(i int) float64 { return float64(d.view.lens[i]) })
+}
+
+// LWLDispatch sends to the queue with the least work left, the sum of the
+// service times of its requests. Queues that are not WorkQueues count one
+// unit of work per request
+type LWLDispatch struct {
+	randSource
+	view queueView
+	all  []int
+}
+
+// NewLWLDispatch returns a new *LWLDispatch that sees queue work up to
+// stale time units old
+func NewLWLDispatch(stale float64) *LWLDispatch {
+	return &LWLDispatch{view: queueView{stale: stale}}
+}
+
+// Select returns the queue with the least work
+func (d *LWLDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	d.view.refresh(queues, now, true)
+	for len(d.all) < len(queues) {
+		d.all = append(d.all, len(d.all))
+	}
+	return pickMin(d.rng, d.all[:len(queues)], func(i int) float64 { return d.view.work[i] })
+}
+
+// WeightedRandomDispatch picks queue i with probability proportional to
+// weights[i]
+type WeightedRandomDispatch struct {
+	randSource
+	cdf []float64
+}
+
+// NewWeightedRandomDispatch returns a new *WeightedRandomDispatch. There
+// should be a weight per out queue. Weights should not be negative and at
+// least one should be positive
+func NewWeightedRandomDispatch(weights []float64) (*WeightedRandomDispatch, error) {
+	d := &WeightedRandomDispatch{}
+	var total float64
+	for i, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("dispatch: negative weight %v of queue %v", w, i)
+		}
+		total += w
+		d.cdf = append(d.cdf, total)
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("dispatch: no positive weight")
+	}
+	for i := range d.cdf {
+		d.cdf[i] /= total
+	}
+	return d, nil
+}
+
+// Queues returns the number of weights, which should be the number of out
+// queues
+func (d *WeightedRandomDispatch) Queues() int {
+	return len(d.cdf)
+}
+
+// Select returns a random queue by weight
+func (d *WeightedRandomDispatch) Select(queues []engine.QueueInterface, req engine.ReqInterface, now float64) int {
+	u := d.rng.Float64()
+	for i, c := range d.cdf {
+		if u < c {
+			return i
+		}
+	}
+	return len(d.cdf) - 1
+}
+
+// ParseDispatchPolicy returns the dispatch policy described by a spec
+// string: random, rr, jsq, pod:d, lwl or weighted:w1,w2,... The load aware
+// policies see queue information up to stale time units old
+func ParseDispatchPolicy(spec string, stale float64) (DispatchPolicy, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	switch name {
+	case "random":
+		return &RandomDispatch{}, nil
+	case "rr":
+		return &RoundRobinDispatch{}, nil
+	case "jsq":
+		return NewJSQDispatch(stale), nil
+	case "lwl":
+		return NewLWLDispatch(stale), nil
+	case "pod":
+		d, err := strconv.Atoi(args)
+		if err != nil || d < 1 {
+			return nil, fmt.Errorf("dispatch %q: pod needs a positive number of choices", spec)
+		}
+		return NewPowerOfDDispatch(d, stale), nil
+	case "weighted":
+		var weights []float64
+		for _, a := range strings.Split(args, ",") {
+			w, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("dispatch %q: bad weight %q", spec, a)
+			}
+			weights = append(weights, w)
+		}
+		d, err := NewWeightedRandomDispatch(weights)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("dispatch %q: unknown policy %q", spec, name)
+}
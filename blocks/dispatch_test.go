@@ -0,0 +1,145 @@
+package blocks_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// newQueues returns a queue per entry of work, holding a request per
+// service time
+func newQueues(work [][]float64) []engine.QueueInterface {
+	var queues []engine.QueueInterface
+	for _, w := range work {
+		q := blocks.NewQueue()
+		for _, s := range w {
+			q.Enqueue(&blocks.Request{ServiceTime: s})
+		}
+		queues = append(queues, q)
+	}
+	return queues
+}
+
+func mustParseDispatch(t *testing.T, spec string) blocks.DispatchPolicy {
+	t.Helper()
+	d, err := blocks.ParseDispatchPolicy(spec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDispatchSelect(t *testing.T) {
+	const n = 100000
+	tests := []struct {
+		name  string
+		spec  string
+		work  [][]float64
+		first []int     // the first selections, if they are fixed
+		freq  []float64 // the fraction of the selections of every queue
+	}{
+		{"rr", "rr", [][]float64{{}, {}, {}}, []int{0, 1, 2, 0, 1}, []float64{1. / 3, 1. / 3, 1. / 3}},
+		{"random", "random", [][]float64{{1}, {}, {1, 1}}, nil, []float64{1. / 3, 1. / 3, 1. / 3}},
+		{"jsq", "jsq", [][]float64{{1, 1}, {}, {1}}, []int{1, 1, 1}, []float64{0, 1, 0}},
+		{"jsq ties", "jsq", [][]float64{{1}, {}, {}}, nil, []float64{0, 0.5, 0.5}},
+		{"pod:2", "pod:2", [][]float64{{}, {1}, {1, 1}}, nil, []float64{2. / 3, 1. / 3, 0}},
+		{"pod:3", "pod:3", [][]float64{{1, 1}, {}, {1}}, []int{1, 1, 1}, []float64{0, 1, 0}},
+		{"pod more than queues", "pod:5", [][]float64{{1}, {}}, []int{1, 1}, []float64{0, 1}},
+		{"lwl", "lwl", [][]float64{{10}, {1, 1, 1}, {5}}, []int{1, 1, 1}, []float64{0, 1, 0}},
+		{"weighted", "weighted:1,3", [][]float64{{}, {}}, nil, []float64{0.25, 0.75}},
+		{"weighted zero", "weighted:0,1,0", [][]float64{{}, {}, {}}, []int{1, 1, 1}, []float64{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := mustParseDispatch(t, tt.spec)
+			d.SetRand(rand.New(rand.NewSource(1)))
+			queues := newQueues(tt.work)
+			for i, want := range tt.first {
+				if got := d.Select(queues, nil, 0); got != want {
+					t.Fatalf("selection %v = %v, want %v", i, got, want)
+				}
+			}
+			counts := make([]float64, len(queues))
+			for i := 0; i < n; i++ {
+				counts[d.Select(queues, nil, 0)]++
+			}
+			for i, want := range tt.freq {
+				if got := counts[i] / n; math.Abs(got-want) > 0.01 {
+					t.Errorf("queue %v got %v of the selections, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchStale(t *testing.T) {
+	d := blocks.NewJSQDispatch(10)
+	d.SetRand(rand.New(rand.NewSource(1)))
+	queues := newQueues([][]float64{{1}, {}})
+	tests := []struct {
+		now  float64
+		want int
+	}{
+		{0, 1},
+		{5, 1},
+		{9.9, 1},
+		{10, 0},
+		{15, 0},
+	}
+	for _, tt := range tests {
+		got := d.Select(queues, nil, tt.now)
+		if got != tt.want {
+			t.Fatalf("select at %v = %v, want %v", tt.now, got, tt.want)
+		}
+		// the policy should not see the new requests till the next refresh
+		queues[got].Enqueue(&blocks.Request{ServiceTime: 1})
+		queues[got].Enqueue(&blocks.Request{ServiceTime: 1})
+	}
+}
+
+func TestParseDispatchPolicyErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"jsf",
+		"pod",
+		"pod:0",
+		"pod:x",
+		"weighted",
+		"weighted:1,x",
+		"weighted:1,-1",
+		"weighted:0,0",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			if d, err := blocks.ParseDispatchPolicy(spec, 0); err == nil {
+				t.Errorf("got %T, want an error", d)
+			}
+		})
+	}
+}
+
+func TestCheckDispatch(t *testing.T) {
+	tests := []struct {
+		spec    string
+		queues  int
+		wantErr bool
+	}{
+		{"rr", 0, true},
+		{"rr", 3, false},
+		{"jsq", 1, false},
+		{"weighted:1,2", 1, true},
+		{"weighted:1,2", 2, false},
+		{"weighted:1,2", 3, true},
+	}
+	for _, tt := range tests {
+		g := blocks.NewComposedGenerator(blocks.NewDeterministicDistr(1), blocks.NewDeterministicDistr(1), mustParseDispatch(t, tt.spec))
+		for i := 0; i < tt.queues; i++ {
+			g.AddOutQueue(blocks.NewQueue())
+		}
+		if err := g.CheckDispatch(); (err != nil) != tt.wantErr {
+			t.Errorf("%v with %v out queues: got error %v, want error %v", tt.spec, tt.queues, err, tt.wantErr)
+		}
+	}
+}
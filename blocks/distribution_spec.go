@@ -0,0 +1,202 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseDistribution returns the distribution described by a spec string of
+// the form name:p1,p2,... It returns an error for parameters out of the
+// range of the distribution. The names and their parameters are
+//
+//	det:d                   DeterministicDistr
+//	exp:lambda              ExponDistr
+//	lognormal:mu,sigma      LGDistr
+//	bimodal:v1,v2,ratio     BiDistr
+//	pareto:alpha,l,h        BoundedParetoDistr
+//	gamma:k,theta           GammaDistr
+//	weibull:k,lambda        WeibullDistr
+//	uniform:a,b             UniformDistr
+//	hyperexp:p1,l1,p2,l2... HyperExpDistr
+//	erlang:k,lambda         ErlangDistr
+//	mmpp2:l1,l2,r12,r21     MMPPArrivals with two states
+//	mmpp:n,l1..ln,r11..rnn  MMPPArrivals with n states, the rates of the
+//	                        states and then the transition rates row by row
+//	onoff:lambda,on,off     OnOffArrivals with exponential periods of mean on and off
+//	file:path               EmpiricalDistr loaded from path
+//	file-interp:path        EmpiricalDistr loaded from path, interpolated
+func ParseDistribution(spec string) (Distribution, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	switch name {
+	case "file":
+		return LoadEmpiricalDistr(args, false)
+	case "file-interp":
+		return LoadEmpiricalDistr(args, true)
+	}
+
+	var params []float64
+	if args != "" {
+		for _, a := range strings.Split(args, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			if err != nil {
+				return nil, fmt.Errorf("distribution %q: %v", spec, err)
+			}
+			params = append(params, p)
+		}
+	}
+	expect := func(n int) error {
+		if len(params) != n {
+			return fmt.Errorf("distribution %q: %v takes %v parameters, got %v", spec, name, n, len(params))
+		}
+		return nil
+	}
+
+	invalid := func(need string) error {
+		return fmt.Errorf("distribution %q: %v needs %v", spec, name, need)
+	}
+	nonNegative := func(ps []float64) bool {
+		for _, p := range ps {
+			if p < 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch name {
+	case "det":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		if params[0] < 0 {
+			return nil, invalid("a non-negative value")
+		}
+		return NewDeterministicDistr(params[0]), nil
+	case "exp":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		if params[0] <= 0 {
+			return nil, invalid("a positive rate")
+		}
+		return NewExponDistr(params[0]), nil
+	case "lognormal":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		if params[1] < 0 {
+			return nil, invalid("a non-negative sigma")
+		}
+		return NewLGDistr(params[0], params[1]), nil
+	case "bimodal":
+		if err := expect(3); err != nil {
+			return nil, err
+		}
+		if !nonNegative(params[:2]) {
+			return nil, invalid("non-negative values")
+		}
+		if params[2] < 0 || params[2] > 1 {
+			return nil, invalid("a ratio in [0, 1]")
+		}
+		return NewBiDistr(params[0], params[1], params[2]), nil
+	case "pareto":
+		if err := expect(3); err != nil {
+			return nil, err
+		}
+		if params[0] <= 0 {
+			return nil, invalid("a positive alpha")
+		}
+		if params[1] <= 0 || params[1] >= params[2] {
+			return nil, invalid("bounds 0 < l < h")
+		}
+		return NewBoundedParetoDistr(params[0], params[1], params[2]), nil
+	case "gamma":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		if params[0] <= 0 || params[1] <= 0 {
+			return nil, invalid("a positive shape and scale")
+		}
+		return NewGammaDistr(params[0], params[1]), nil
+	case "weibull":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		if params[0] <= 0 || params[1] <= 0 {
+			return nil, invalid("a positive shape and scale")
+		}
+		return NewWeibullDistr(params[0], params[1]), nil
+	case "uniform":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		if params[0] < 0 || params[0] > params[1] {
+			return nil, invalid("bounds 0 <= a <= b")
+		}
+		return NewUniformDistr(params[0], params[1]), nil
+	case "hyperexp":
+		if len(params) == 0 || len(params)%2 != 0 {
+			return nil, fmt.Errorf("distribution %q: hyperexp takes probability and rate pairs", spec)
+		}
+		var probs, lambdas []float64
+		var sum float64
+		for i := 0; i < len(params); i += 2 {
+			if params[i] < 0 || params[i+1] <= 0 {
+				return nil, invalid("non-negative probabilities and positive rates")
+			}
+			probs = append(probs, params[i])
+			lambdas = append(lambdas, params[i+1])
+			sum += params[i]
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			return nil, invalid("probabilities adding up to 1")
+		}
+		return NewHyperExpDistr(probs, lambdas), nil
+	case "erlang":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		if params[0] < 1 || params[0] != float64(int(params[0])) {
+			return nil, fmt.Errorf("distribution %q: erlang needs a positive integer k", spec)
+		}
+		if params[1] <= 0 {
+			return nil, invalid("a positive rate")
+		}
+		return NewErlangDistr(int(params[0]), params[1]), nil
+	case "mmpp2":
+		if err := expect(4); err != nil {
+			return nil, err
+		}
+		if !nonNegative(params) {
+			return nil, invalid("non-negative rates")
+		}
+		return NewMMPPArrivals(params[:2], [][]float64{{0, params[2]}, {params[3], 0}}), nil
+	case "mmpp":
+		if len(params) == 0 || params[0] < 1 || params[0] != float64(int(params[0])) {
+			return nil, fmt.Errorf("distribution %q: mmpp needs a positive integer number of states", spec)
+		}
+		n := int(params[0])
+		if err := expect(1 + n + n*n); err != nil {
+			return nil, err
+		}
+		if !nonNegative(params) {
+			return nil, invalid("non-negative rates")
+		}
+		transitions := make([][]float64, n)
+		for i := range transitions {
+			transitions[i] = params[1+n+i*n : 1+n+(i+1)*n]
+		}
+		return NewMMPPArrivals(params[1:1+n], transitions), nil
+	case "onoff":
+		if err := expect(3); err != nil {
+			return nil, err
+		}
+		if params[0] <= 0 || params[1] <= 0 || params[2] <= 0 {
+			return nil, invalid("a positive rate and positive mean periods")
+		}
+		return NewOnOffArrivals(params[0], NewExponDistr(1/params[1]), NewExponDistr(1/params[2])), nil
+	}
+	return nil, fmt.Errorf("distribution %q: unknown distribution %q", spec, name)
+}
@@ -0,0 +1,84 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		spec string
+		mean float64
+	}{
+		{"det:2", 2},
+		{"exp:0.5", 2},
+		{"lognormal:0,1", math.Exp(0.5)},
+		{"bimodal:1,11,0.9", 2},
+		{"gamma:2,3", 6},
+		{"weibull:1,2", 2},
+		{"uniform:1,3", 2},
+		{"hyperexp:0.5,1,0.5,0.25", 2.5},
+		{"erlang:3,1.5", 2},
+		{"mmpp2:1,3,1,1", 0.5},
+		{"mmpp:2,1,3,0,1,1,0", 0.5},
+		{"mmpp:3,1,2,3,0,1,0,0,0,1,1,0,0", 0.5},
+		{"onoff:2,1,1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			d, err := blocks.ParseDistribution(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(d.Mean()-tt.mean) > 1e-9 {
+				t.Errorf("mean %v, want %v", d.Mean(), tt.mean)
+			}
+		})
+	}
+}
+
+func TestParseDistributionErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"foo:1",
+		"exp",
+		"exp:x",
+		"exp:1,2",
+		"exp:0",
+		"exp:-1",
+		"det:-1",
+		"lognormal:0,-1",
+		"bimodal:1,2,1.5",
+		"bimodal:1,2,-0.1",
+		"bimodal:-1,2,0.5",
+		"pareto:0,1,10",
+		"pareto:1.5,10,10",
+		"pareto:1.5,0,10",
+		"gamma:0,1",
+		"gamma:1,-1",
+		"weibull:-1,1",
+		"weibull:1,0",
+		"uniform:3,1",
+		"uniform:-1,1",
+		"hyperexp:0.5,1",
+		"hyperexp:0.5,1,0.4",
+		"hyperexp:1.5,1,-0.5,1",
+		"hyperexp:0.5,1,0.5,0",
+		"erlang:1.5,1",
+		"erlang:2,0",
+		"mmpp2:1,-1,1,1",
+		"mmpp:0",
+		"mmpp:2,1,1,0,1",
+		"mmpp:2,1,1,0,-1,1,0",
+		"onoff:1,0,1",
+		"onoff:0,1,1",
+		"file:/nonexistent",
+	}
+	for _, spec := range specs {
+		if _, err := blocks.ParseDistribution(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
@@ -5,66 +5,287 @@ import (
 	"math/rand"
 )
 
-type randDist interface {
-	getRand() float64
+// Distribution describes a random variable generators draw service and
+// waiting times from. Each distribution draws from its own random stream,
+// set with SetRand before the first GetRand
+type Distribution interface {
+	GetRand() float64
+	SetRand(r *rand.Rand)
+	Mean() float64
 }
 
-// Deterministic Distribution
-type deterministicDistr struct {
+// randSource keeps the random stream of a distribution
+type randSource struct {
+	rng *rand.Rand
+}
+
+// SetRand sets the random stream of the distribution
+func (s *randSource) SetRand(r *rand.Rand) {
+	s.rng = r
+}
+
+// DeterministicDistr always returns d.
+// Mean: d
+type DeterministicDistr struct {
+	randSource
 	d float64
 }
 
-func newDeterministicDistr(d float64) *deterministicDistr {
-	return &deterministicDistr{d}
+// NewDeterministicDistr returns a new *DeterministicDistr
+func NewDeterministicDistr(d float64) *DeterministicDistr {
+	return &DeterministicDistr{d: d}
 }
 
-func (distr *deterministicDistr) getRand() float64 {
+func (distr *DeterministicDistr) GetRand() float64 {
 	return distr.d
 }
 
-// Exponential Distribution
-type exponDistr struct {
+func (distr *DeterministicDistr) Mean() float64 {
+	return distr.d
+}
+
+// ExponDistr is the exponential distribution with rate lambda.
+// Mean: 1/lambda
+type ExponDistr struct {
+	randSource
 	lambda float64
 }
 
-func newExponDistr(l float64) *exponDistr {
-	return &exponDistr{l}
+// NewExponDistr returns a new *ExponDistr
+func NewExponDistr(l float64) *ExponDistr {
+	return &ExponDistr{lambda: l}
+}
+
+func (distr *ExponDistr) GetRand() float64 {
+	return float64(distr.rng.ExpFloat64() / distr.lambda)
 }
 
-func (distr *exponDistr) getRand() float64 {
-	return float64(rand.ExpFloat64() / distr.lambda)
+func (distr *ExponDistr) Mean() float64 {
+	return 1 / distr.lambda
 }
 
-// LogNormal Distribution
-type lGDistr struct {
+// LGDistr is the lognormal distribution, whose logarithm is normal with
+// mean mu and standard deviation sigma.
+// Mean: exp(mu + sigma^2/2)
+type LGDistr struct {
+	randSource
 	mu    float64
 	sigma float64
 }
 
-func newLGDistr(mu, sigma float64) *lGDistr {
-	return &lGDistr{mu, sigma}
+// NewLGDistr returns a new *LGDistr
+func NewLGDistr(mu, sigma float64) *LGDistr {
+	return &LGDistr{mu: mu, sigma: sigma}
 }
 
-func (distr *lGDistr) getRand() float64 {
-	z := rand.NormFloat64()
+func (distr *LGDistr) GetRand() float64 {
+	z := distr.rng.NormFloat64()
 	s := math.Exp(distr.mu + distr.sigma*z)
 	return s
 }
 
-// Bimodel Distribution
-type biDistr struct {
+func (distr *LGDistr) Mean() float64 {
+	return math.Exp(distr.mu + distr.sigma*distr.sigma/2)
+}
+
+// BiDistr is the bimodal distribution returning v1 with probability ratio
+// and v2 otherwise.
+// Mean: ratio*v1 + (1-ratio)*v2
+type BiDistr struct {
+	randSource
 	v1    float64
 	v2    float64
 	ratio float64
 }
 
-func newBiDistr(v1, v2, ratio float64) *biDistr {
-	return &biDistr{v1, v2, ratio}
+// NewBiDistr returns a new *BiDistr
+func NewBiDistr(v1, v2, ratio float64) *BiDistr {
+	return &BiDistr{v1: v1, v2: v2, ratio: ratio}
 }
 
-func (distr *biDistr) getRand() float64 {
-	if rand.Float64() > distr.ratio {
+func (distr *BiDistr) GetRand() float64 {
+	if distr.rng.Float64() > distr.ratio {
 		return distr.v2
 	}
 	return distr.v1
 }
+
+func (distr *BiDistr) Mean() float64 {
+	return distr.ratio*distr.v1 + (1-distr.ratio)*distr.v2
+}
+
+// BoundedParetoDistr is the Pareto distribution with shape alpha truncated
+// to [l, h].
+// Mean: l^alpha/(1-(l/h)^alpha) * alpha/(alpha-1) * (1/l^(alpha-1) - 1/h^(alpha-1)),
+// or h*l/(h-l) * ln(h/l) if alpha is 1
+type BoundedParetoDistr struct {
+	randSource
+	alpha float64
+	l     float64
+	h     float64
+}
+
+// NewBoundedParetoDistr returns a new *BoundedParetoDistr
+func NewBoundedParetoDistr(alpha, l, h float64) *BoundedParetoDistr {
+	return &BoundedParetoDistr{alpha: alpha, l: l, h: h}
+}
+
+func (distr *BoundedParetoDistr) GetRand() float64 {
+	u := distr.rng.Float64()
+	ratio := math.Pow(distr.l/distr.h, distr.alpha)
+	return distr.l / math.Pow(1-u*(1-ratio), 1/distr.alpha)
+}
+
+func (distr *BoundedParetoDistr) Mean() float64 {
+	a, l, h := distr.alpha, distr.l, distr.h
+	if a == 1 {
+		return h * l / (h - l) * math.Log(h/l)
+	}
+	return math.Pow(l, a) / (1 - math.Pow(l/h, a)) * a / (a - 1) *
+		(1/math.Pow(l, a-1) - 1/math.Pow(h, a-1))
+}
+
+// GammaDistr is the gamma distribution with shape k and scale theta.
+// Mean: k*theta
+type GammaDistr struct {
+	randSource
+	k     float64
+	theta float64
+}
+
+// NewGammaDistr returns a new *GammaDistr
+func NewGammaDistr(k, theta float64) *GammaDistr {
+	return &GammaDistr{k: k, theta: theta}
+}
+
+// GetRand uses the Marsaglia and Tsang method
+func (distr *GammaDistr) GetRand() float64 {
+	return gammaRand(distr.rng, distr.k) * distr.theta
+}
+
+func (distr *GammaDistr) Mean() float64 {
+	return distr.k * distr.theta
+}
+
+// gammaRand draws from the gamma distribution with shape k and scale 1
+func gammaRand(rng *rand.Rand, k float64) float64 {
+	if k < 1 {
+		return gammaRand(rng, k+1) * math.Pow(rng.Float64(), 1/k)
+	}
+	d := k - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// WeibullDistr is the Weibull distribution with shape k and scale lambda.
+// Mean: lambda * Gamma(1 + 1/k)
+type WeibullDistr struct {
+	randSource
+	k      float64
+	lambda float64
+}
+
+// NewWeibullDistr returns a new *WeibullDistr
+func NewWeibullDistr(k, lambda float64) *WeibullDistr {
+	return &WeibullDistr{k: k, lambda: lambda}
+}
+
+func (distr *WeibullDistr) GetRand() float64 {
+	return distr.lambda * math.Pow(distr.rng.ExpFloat64(), 1/distr.k)
+}
+
+func (distr *WeibullDistr) Mean() float64 {
+	return distr.lambda * math.Gamma(1+1/distr.k)
+}
+
+// UniformDistr is the uniform distribution in [a, b).
+// Mean: (a+b)/2
+type UniformDistr struct {
+	randSource
+	a float64
+	b float64
+}
+
+// NewUniformDistr returns a new *UniformDistr
+func NewUniformDistr(a, b float64) *UniformDistr {
+	return &UniformDistr{a: a, b: b}
+}
+
+func (distr *UniformDistr) GetRand() float64 {
+	return distr.a + distr.rng.Float64()*(distr.b-distr.a)
+}
+
+func (distr *UniformDistr) Mean() float64 {
+	return (distr.a + distr.b) / 2
+}
+
+// HyperExpDistr is the hyperexponential distribution, which is exponential
+// with rate lambdas[i] with probability probs[i].
+// Mean: sum of probs[i]/lambdas[i]
+type HyperExpDistr struct {
+	randSource
+	probs   []float64
+	lambdas []float64
+}
+
+// NewHyperExpDistr returns a new *HyperExpDistr. The probabilities should
+// add up to 1
+func NewHyperExpDistr(probs, lambdas []float64) *HyperExpDistr {
+	return &HyperExpDistr{probs: probs, lambdas: lambdas}
+}
+
+func (distr *HyperExpDistr) GetRand() float64 {
+	u := distr.rng.Float64()
+	i := 0
+	for ; i < len(distr.probs)-1; i++ {
+		if u < distr.probs[i] {
+			break
+		}
+		u -= distr.probs[i]
+	}
+	return distr.rng.ExpFloat64() / distr.lambdas[i]
+}
+
+func (distr *HyperExpDistr) Mean() float64 {
+	var m float64
+	for i, p := range distr.probs {
+		m += p / distr.lambdas[i]
+	}
+	return m
+}
+
+// ErlangDistr is the sum of k exponentials with rate lambda.
+// Mean: k/lambda
+type ErlangDistr struct {
+	randSource
+	k      int
+	lambda float64
+}
+
+// NewErlangDistr returns a new *ErlangDistr
+func NewErlangDistr(k int, lambda float64) *ErlangDistr {
+	return &ErlangDistr{k: k, lambda: lambda}
+}
+
+func (distr *ErlangDistr) GetRand() float64 {
+	var s float64
+	for i := 0; i < distr.k; i++ {
+		s += distr.rng.ExpFloat64()
+	}
+	return s / distr.lambda
+}
+
+func (distr *ErlangDistr) Mean() float64 {
+	return float64(distr.k) / distr.lambda
+}
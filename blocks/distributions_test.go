@@ -0,0 +1,55 @@
+package blocks_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+)
+
+func TestDistributionMeans(t *testing.T) {
+	const n = 200000
+	empirical, err := blocks.NewEmpiricalDistr([]float64{1, 2, 10}, []float64{0.5, 0.3, 0.2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interpolated, err := blocks.NewEmpiricalDistr([]float64{1, 2, 10}, []float64{0.5, 0.3, 0.2}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		d    blocks.Distribution
+	}{
+		{"deterministic", blocks.NewDeterministicDistr(3)},
+		{"exponential", blocks.NewExponDistr(0.5)},
+		{"lognormal", blocks.NewLGDistr(1, 0.5)},
+		{"bimodal", blocks.NewBiDistr(1, 10, 0.9)},
+		{"pareto", blocks.NewBoundedParetoDistr(1.5, 1, 100)},
+		{"pareto alpha 1", blocks.NewBoundedParetoDistr(1, 1, 100)},
+		{"gamma", blocks.NewGammaDistr(2.5, 2)},
+		{"gamma shape below 1", blocks.NewGammaDistr(0.5, 2)},
+		{"weibull", blocks.NewWeibullDistr(1.5, 2)},
+		{"uniform", blocks.NewUniformDistr(1, 5)},
+		{"hyperexponential", blocks.NewHyperExpDistr([]float64{0.9, 0.1}, []float64{1, 0.1})},
+		{"erlang", blocks.NewErlangDistr(3, 1.5)},
+		{"empirical", empirical},
+		{"empirical interpolated", interpolated},
+		{"mmpp", blocks.NewMMPPArrivals([]float64{1, 3}, [][]float64{{0, 0.1}, {0.2, 0}})},
+		{"on off", blocks.NewOnOffArrivals(2, blocks.NewExponDistr(1), blocks.NewExponDistr(0.5))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.SetRand(rand.New(rand.NewSource(1)))
+			var sum float64
+			for i := 0; i < n; i++ {
+				sum += tt.d.GetRand()
+			}
+			mean, want := sum/n, tt.d.Mean()
+			if math.Abs(mean-want) > 0.02*want {
+				t.Errorf("sample mean %v, want %v", mean, want)
+			}
+		})
+	}
+}
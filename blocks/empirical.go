@@ -0,0 +1,150 @@
+package blocks
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EmpiricalDistr samples a measured distribution by inverting its CDF. It
+// is built from (value, probability) buckets or from raw samples, which are
+// buckets of equal probability. Without interpolation it returns bucket
+// values. With interpolation bucket i covers the values between bucket i-1
+// and bucket i, and is sampled uniformly.
+// Mean: sum of p[i]*v[i], or sum of p[i]*(v[i-1]+v[i])/2 with interpolation
+type EmpiricalDistr struct {
+	randSource
+	values      []float64
+	cdf         []float64
+	interpolate bool
+}
+
+// NewEmpiricalDistr returns a new *EmpiricalDistr with the given buckets.
+// The probabilities are normalized to add up to 1
+func NewEmpiricalDistr(values, probs []float64, interpolate bool) (*EmpiricalDistr, error) {
+	if len(values) == 0 || len(values) != len(probs) {
+		return nil, fmt.Errorf("empirical distribution: %v values and %v probabilities", len(values), len(probs))
+	}
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+
+	distr := &EmpiricalDistr{interpolate: interpolate}
+	var total float64
+	for _, i := range idx {
+		if probs[i] < 0 {
+			return nil, fmt.Errorf("empirical distribution: negative probability %v", probs[i])
+		}
+		total += probs[i]
+		distr.values = append(distr.values, values[i])
+		distr.cdf = append(distr.cdf, total)
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("empirical distribution: probabilities add up to %v", total)
+	}
+	for i := range distr.cdf {
+		distr.cdf[i] /= total
+	}
+	return distr, nil
+}
+
+// NewEmpiricalDistrSamples returns a new *EmpiricalDistr with the empirical
+// CDF of the given samples
+func NewEmpiricalDistrSamples(samples []float64, interpolate bool) (*EmpiricalDistr, error) {
+	probs := make([]float64, len(samples))
+	for i := range probs {
+		probs[i] = 1
+	}
+	return NewEmpiricalDistr(samples, probs, interpolate)
+}
+
+// LoadEmpiricalDistr reads an empirical distribution from a CSV file. Lines
+// have either a value and its probability, or a single raw sample. A
+// non-numeric first line is taken as a header and blank lines are skipped
+func LoadEmpiricalDistr(path string, interpolate bool) (*EmpiricalDistr, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values, probs []float64
+	fields := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		record := strings.Split(text, ",")
+		if fields == 0 {
+			fields = len(record)
+		}
+		if len(record) != fields || fields > 2 {
+			return nil, fmt.Errorf("%v:%v: expected %v fields, got %v", path, line, min(fields, 2), len(record))
+		}
+		nums := make([]float64, len(record))
+		for i, r := range record {
+			nums[i], err = strconv.ParseFloat(strings.TrimSpace(r), 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if line == 1 {
+				err = nil
+				fields = 0
+				continue
+			}
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		values = append(values, nums[0])
+		if fields == 2 {
+			probs = append(probs, nums[1])
+		} else {
+			probs = append(probs, 1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	distr, err := NewEmpiricalDistr(values, probs, interpolate)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return distr, nil
+}
+
+func (distr *EmpiricalDistr) GetRand() float64 {
+	u := distr.rng.Float64()
+	i := sort.Search(len(distr.cdf), func(i int) bool { return distr.cdf[i] > u })
+	if i == len(distr.cdf) {
+		// rounding in the normalized cdf
+		i--
+	}
+	if !distr.interpolate || i == 0 {
+		return distr.values[i]
+	}
+	lo, hi := distr.values[i-1], distr.values[i]
+	frac := (u - distr.cdf[i-1]) / (distr.cdf[i] - distr.cdf[i-1])
+	return lo + frac*(hi-lo)
+}
+
+func (distr *EmpiricalDistr) Mean() float64 {
+	var m, prev float64
+	for i, v := range distr.values {
+		p := distr.cdf[i] - prev
+		prev = distr.cdf[i]
+		if distr.interpolate && i > 0 {
+			m += p * (distr.values[i-1] + v) / 2
+		} else {
+			m += p * v
+		}
+	}
+	return m
+}
@@ -0,0 +1,203 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// RateProfile is an arrival rate that changes with time. The profile is
+// split in load phases, which requests are tagged with. End returns the
+// time after which the rate stays zero, or +Inf if it never does
+type RateProfile interface {
+	Rate(t float64) float64
+	MaxRate() float64
+	Phase(t float64) int
+	End() float64
+}
+
+// PiecewiseLinearProfile goes linearly from rates[i] at times[i] to
+// rates[i+1] at times[i+1]. The rate is rates[0] before times[0] and the
+// last rate after the last time. Repeating a time gives a step. Load phase
+// i lasts from times[i] to times[i+1]
+type PiecewiseLinearProfile struct {
+	times []float64
+	rates []float64
+}
+
+// NewPiecewiseLinearProfile returns a new *PiecewiseLinearProfile. The
+// times should be increasing
+func NewPiecewiseLinearProfile(times, rates []float64) *PiecewiseLinearProfile {
+	return &PiecewiseLinearProfile{times: times, rates: rates}
+}
+
+// Phase returns the index of the last point at or before t
+func (p *PiecewiseLinearProfile) Phase(t float64) int {
+	i := 0
+	for i < len(p.times)-1 && p.times[i+1] <= t {
+		i++
+	}
+	return i
+}
+
+func (p *PiecewiseLinearProfile) Rate(t float64) float64 {
+	i := p.Phase(t)
+	if t < p.times[0] || i == len(p.times)-1 {
+		return p.rates[i]
+	}
+	frac := (t - p.times[i]) / (p.times[i+1] - p.times[i])
+	return p.rates[i] + frac*(p.rates[i+1]-p.rates[i])
+}
+
+func (p *PiecewiseLinearProfile) MaxRate() float64 {
+	m := 0.0
+	for _, r := range p.rates {
+		m = math.Max(m, r)
+	}
+	return m
+}
+
+// End returns the time of the point after the last positive rate
+func (p *PiecewiseLinearProfile) End() float64 {
+	last := len(p.rates) - 1
+	if p.rates[last] > 0 {
+		return math.Inf(1)
+	}
+	for i := last - 1; i >= 0; i-- {
+		if p.rates[i] > 0 {
+			return p.times[i+1]
+		}
+	}
+	return math.Inf(-1)
+}
+
+// SinusoidalProfile is mean + amplitude*sin(2*pi*t/period). Every period is
+// split in the given number of equal load phases
+type SinusoidalProfile struct {
+	mean      float64
+	amplitude float64
+	period    float64
+	phases    int
+}
+
+// NewSinusoidalProfile returns a new *SinusoidalProfile. The amplitude
+// should not be larger than the mean
+func NewSinusoidalProfile(mean, amplitude, period float64, phases int) *SinusoidalProfile {
+	return &SinusoidalProfile{mean: mean, amplitude: amplitude, period: period, phases: phases}
+}
+
+func (p *SinusoidalProfile) Rate(t float64) float64 {
+	return p.mean + p.amplitude*math.Sin(2*math.Pi*t/p.period)
+}
+
+func (p *SinusoidalProfile) MaxRate() float64 {
+	return p.mean + math.Abs(p.amplitude)
+}
+
+// End returns +Inf, the rate is periodic
+func (p *SinusoidalProfile) End() float64 {
+	return math.Inf(1)
+}
+
+func (p *SinusoidalProfile) Phase(t float64) int {
+	return int(t/p.period*float64(p.phases)) % p.phases
+}
+
+// ParseRateProfile returns the rate profile described by a spec string:
+// linear:t0,r0,t1,r1,... for a PiecewiseLinearProfile or
+// sin:mean,amplitude,period,phases for a SinusoidalProfile. Rates should
+// not be negative and should not all be zero
+func ParseRateProfile(spec string) (RateProfile, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	var params []float64
+	for _, a := range strings.Split(args, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		if err != nil {
+			return nil, fmt.Errorf("load profile %q: %v", spec, err)
+		}
+		params = append(params, p)
+	}
+	switch name {
+	case "linear":
+		if len(params) == 0 || len(params)%2 != 0 {
+			return nil, fmt.Errorf("load profile %q: linear takes time and rate pairs", spec)
+		}
+		var times, rates []float64
+		for i := 0; i < len(params); i += 2 {
+			if i > 0 && params[i] < params[i-2] {
+				return nil, fmt.Errorf("load profile %q: times should be increasing", spec)
+			}
+			if params[i+1] < 0 {
+				return nil, fmt.Errorf("load profile %q: negative rate %v", spec, params[i+1])
+			}
+			times = append(times, params[i])
+			rates = append(rates, params[i+1])
+		}
+		p := NewPiecewiseLinearProfile(times, rates)
+		if p.MaxRate() <= 0 {
+			return nil, fmt.Errorf("load profile %q: every rate is zero", spec)
+		}
+		return p, nil
+	case "sin":
+		if len(params) != 4 || params[3] < 1 {
+			return nil, fmt.Errorf("load profile %q: sin takes mean, amplitude, period and phases", spec)
+		}
+		if params[0] <= 0 || math.Abs(params[1]) > params[0] || params[2] <= 0 {
+			return nil, fmt.Errorf("load profile %q: sin needs a positive mean and period and an amplitude not larger than the mean", spec)
+		}
+		return NewSinusoidalProfile(params[0], params[1], params[2], int(params[3])), nil
+	}
+	return nil, fmt.Errorf("load profile %q: unknown profile %q", spec, name)
+}
+
+// ProfileGenerator is a non-homogeneous Poisson generator whose rate
+// follows a RateProfile. Arrivals are drawn by thinning: candidates of a
+// Poisson process at the maximum rate are kept with probability
+// rate/maximum rate. Requests are tagged with the load phase they arrived in
+type ProfileGenerator struct {
+	ComposedGenerator
+	profile RateProfile
+}
+
+// NewProfileGenerator returns a ProfileGenerator
+func NewProfileGenerator(profile RateProfile, serviceTime Distribution, dispatch DispatchPolicy) *ProfileGenerator {
+	g := &ProfileGenerator{profile: profile}
+	g.ServiceTime = serviceTime
+	g.WaitTime = NewExponDistr(profile.MaxRate())
+	g.Dispatch = dispatch
+	return g
+}
+
+// Run is the main loop of the generator. It returns once the generator is
+// exhausted, or once the rate of the profile stays zero
+func (g *ProfileGenerator) Run() {
+	g.initRand()
+	g.initDispatch()
+	max, end := g.profile.MaxRate(), g.profile.End()
+	for {
+		t := g.GetTime()
+		for {
+			t += g.WaitTime.GetRand()
+			if t >= end {
+				return
+			}
+			if g.Rand().Float64()*max < g.profile.Rate(t) {
+				break
+			}
+		}
+		g.Wait(t - g.GetTime())
+
+		phase := g.profile.Phase(g.GetTime())
+		g.arrive(func(req engine.ReqInterface) {
+			if tagged, ok := req.(LoadPhaseReq); ok {
+				tagged.SetLoadPhase(phase)
+			}
+		})
+		if g.Exhausted() {
+			return
+		}
+	}
+}
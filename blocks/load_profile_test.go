@@ -0,0 +1,91 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+func TestParseRateProfile(t *testing.T) {
+	tests := []struct {
+		spec    string
+		end     float64
+		wantErr bool
+	}{
+		{"linear:0,1,100,2", math.Inf(1), false},
+		{"linear:0,0.01,100,0", 100, false},
+		{"linear:0,0,10,1,20,0,30,0", 20, false},
+		{"linear:0,1", math.Inf(1), false},
+		{"sin:1,1,10,4", math.Inf(1), false},
+		{"sin:1,-0.5,10,4", math.Inf(1), false},
+		{"linear:0,0,100,0", 0, true},
+		{"linear:0,1,100,-1", 0, true},
+		{"linear:10,1,0,1", 0, true},
+		{"linear:0,1,100", 0, true},
+		{"sin:1,2,10,4", 0, true},
+		{"sin:0,0,10,4", 0, true},
+		{"sin:1,0.5,0,4", 0, true},
+		{"sin:1,0.5,10,0", 0, true},
+		{"step:0,1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := blocks.ParseRateProfile(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %T, want an error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.End(); got != tt.end {
+				t.Errorf("end %v, want %v", got, tt.end)
+			}
+		})
+	}
+}
+
+// counter counts the finished requests
+type counter struct {
+	n int
+}
+
+func (c *counter) TerminateReq(req engine.ReqInterface, now float64) {
+	c.n++
+}
+
+func (c *counter) SetName(name string) {}
+
+func TestProfileGeneratorEnd(t *testing.T) {
+	profile, err := blocks.ParseRateProfile("linear:0,0.5,100,0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := engine.NewSimulation(1)
+	g := blocks.NewProfileGenerator(profile, blocks.NewDeterministicDistr(0.1), &blocks.RandomDispatch{})
+	g.SetCreator(&blocks.SimpleReqCreator{})
+	q := blocks.NewQueue()
+	g.AddOutQueue(q)
+	p := &blocks.RTCProcessor{}
+	p.AddInQueue(q)
+	done := &counter{}
+	p.SetReqDrain(done)
+	sim.RegisterActor(p)
+	sim.RegisterActor(g)
+	// the generator stops once the rate stays zero, so the run ends long
+	// before the threshold
+	if err := sim.Run(1e9, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if sim.GetTime() > 101 {
+		t.Errorf("run ended at %v, want before 101", sim.GetTime())
+	}
+	// the mean number of arrivals is 25
+	if done.n < 10 || done.n > 40 {
+		t.Errorf("finished %v requests, want about 25", done.n)
+	}
+}
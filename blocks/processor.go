@@ -30,6 +30,19 @@ func (p *genericProcessor) SetCtxCost(cost float64) {
 	p.ctxCost = cost
 }
 
+// switchContext waits for the context switch cost, if any
+func (p *genericProcessor) switchContext() {
+	if p.ctxCost > 0 {
+		p.WaitFor(p.ctxCost, engine.WaitCtxSwitch)
+	}
+}
+
+// terminate reports the request as finished and hands it to the drain
+func (p *genericProcessor) terminate(req engine.ReqInterface) {
+	p.ReportTermination(req)
+	p.reqDrain.TerminateReq(req, p.GetTime())
+}
+
 // RTCProcessor is a run to completion processor
 type RTCProcessor struct {
 	genericProcessor
@@ -40,11 +53,12 @@ type RTCProcessor struct {
 func (p *RTCProcessor) Run() {
 	for {
 		req := p.ReadInQueue()
-		p.Wait(req.GetServiceTime() + p.ctxCost)
+		p.switchContext()
+		p.Wait(req.GetServiceTime())
 		if monitorReq, ok := req.(*MonitorReq); ok {
 			monitorReq.finalLength = p.GetInQueueLen(0)
 		}
-		p.reqDrain.TerminateReq(req)
+		p.terminate(req)
 	}
 }
 
@@ -65,10 +79,12 @@ func (p *TSProcessor) Run() {
 		req := p.ReadInQueue()
 
 		if req.GetServiceTime() <= p.quantum {
-			p.Wait(req.GetServiceTime() + p.ctxCost)
-			p.reqDrain.TerminateReq(req)
+			p.switchContext()
+			p.Wait(req.GetServiceTime())
+			p.terminate(req)
 		} else {
-			p.Wait(p.quantum + p.ctxCost)
+			p.switchContext()
+			p.Wait(p.quantum)
 			req.SubServiceTime(p.quantum)
 			p.WriteInQueue(req)
 		}
@@ -116,7 +132,7 @@ func (p *PSProcessor) getFactor() float64 {
 }
 
 func (p *PSProcessor) updateServiceTimes() {
-	currTime := engine.GetTime()
+	currTime := p.GetTime()
 	diff := (currTime - p.prevTime) * p.getFactor()
 	p.prevTime = currTime
 	for e := p.reqList.Front(); e != nil; e = e.Next() {
@@ -130,12 +146,13 @@ func (p *PSProcessor) Run() {
 	var d float64
 	d = -1
 	for {
-		intr, newReq := p.WaitInterruptible(d)
+		// the timeout is the completion of the shortest request in service
+		intr, newReq := p.WaitInterruptibleFor(d, engine.WaitService)
 		//update times
 		p.updateServiceTimes()
 		if intr {
 			req := p.curr.Value.(engine.ReqInterface)
-			p.reqDrain.TerminateReq(req)
+			p.terminate(req)
 			p.reqList.Remove(p.curr)
 			p.count--
 		} else {
@@ -178,7 +195,7 @@ func (p *BoundedProcessor) Run() {
 		if len < p.bufSize {
 			p.WriteOutQueue(req)
 		} else {
-			p.reqDrain.TerminateReq(req)
+			p.terminate(req)
 		}
 	}
 }
@@ -201,6 +218,6 @@ func (p *BoundedProcessor2) Run() {
 			}
 		}
 		p.Wait(factor * req.GetServiceTime())
-		p.reqDrain.TerminateReq(req)
+		p.terminate(req)
 	}
 }
@@ -4,30 +4,33 @@ import (
 	//"container/heap"
 	"container/list"
 	//"sort"
-	//"fmt"
+	"fmt"
+	"sync/atomic"
+
 	"github.com/neel-patel-1/xmp_sched_sim/engine"
 )
 
-var count = 0
+// count hands out queue ids. It is shared by all simulations in the process
+var count atomic.Int64
 
 // Queue is a imple FIFO queue
 type Queue struct {
-	l  *list.List
-	id int
+	l     *list.List
+	id    int64
+	label string
 }
 
 // NewQueue returns a new *Queue
 func NewQueue() *Queue {
 	q := &Queue{}
 	q.l = list.New()
-	q.id = count
-	count++
+	q.id = count.Add(1) - 1
 	return q
 }
 
 // Enqueue enqueues a new ReqInterface at the queue
 func (q *Queue) Enqueue(el engine.ReqInterface) {
-	//fmt.Printf("time: %v, queue: %v, len: %v\n", engine.GetTime(), q.id, q.Len())
+	//fmt.Printf("queue: %v, len: %v\n", q.id, q.Len())
 	q.l.PushBack(el)
 }
 
@@ -38,7 +41,55 @@ func (q *Queue) Dequeue() engine.ReqInterface {
 	return el.Value.(engine.ReqInterface)
 }
 
+// SetLabel names the queue in diagnostics and statistics instead of its id
+func (q *Queue) SetLabel(label string) {
+	q.label = label
+}
+
+// String returns the queue name, used in diagnostics
+func (q *Queue) String() string {
+	if q.label != "" {
+		return q.label
+	}
+	return fmt.Sprintf("queue %v", q.id)
+}
+
+// Work returns the sum of the service times of the requests in the queue
+func (q *Queue) Work() float64 {
+	var w float64
+	for e := q.l.Front(); e != nil; e = e.Next() {
+		w += e.Value.(engine.ReqInterface).GetServiceTime()
+	}
+	return w
+}
+
 // Len returns the queue length
 func (q *Queue) Len() int {
 	return q.l.Len()
 }
+
+// BoundedQueue is a FIFO queue with a limited capacity.
+// Actors writing with WriteOutQueueIBlocking block while it is full
+type BoundedQueue struct {
+	Queue
+	capacity int
+}
+
+// NewBoundedQueue returns a new *BoundedQueue
+func NewBoundedQueue(capacity int) *BoundedQueue {
+	return &BoundedQueue{Queue: *NewQueue(), capacity: capacity}
+}
+
+// Enqueue enqueues a new ReqInterface at the queue.
+// It panics if the queue is full
+func (q *BoundedQueue) Enqueue(el engine.ReqInterface) {
+	if q.Len() >= q.capacity {
+		panic(fmt.Sprintf("%v: enqueue on full queue", q))
+	}
+	q.Queue.Enqueue(el)
+}
+
+// Cap returns the queue capacity
+func (q *BoundedQueue) Cap() int {
+	return q.capacity
+}
@@ -0,0 +1,114 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+type queueOccupancy struct {
+	q        engine.QueueInterface
+	length   int
+	last     float64
+	area     float64
+	maxLen   int
+	enqueued int
+	dequeued int
+	sojourn  float64
+	arrivals []float64
+}
+
+// QueueMonitor is an engine.Observer that keeps time weighted occupancy
+// statistics for every queue actors enqueue to. It is also an engine.Stats,
+// so it only accounts for the measurement window and prints a line per
+// queue at the end of the simulation. Sojourn times assume FIFO queues.
+// Register it with both AddObserver and InitStats
+type QueueMonitor struct {
+	engine.NopObserver
+	window engine.Window
+	queues map[engine.QueueInterface]*queueOccupancy
+	order  []*queueOccupancy
+}
+
+// NewQueueMonitor returns a new *QueueMonitor
+func NewQueueMonitor() *QueueMonitor {
+	return &QueueMonitor{queues: make(map[engine.QueueInterface]*queueOccupancy)}
+}
+
+// SetWindow sets the measurement window
+func (m *QueueMonitor) SetWindow(w engine.Window) {
+	m.window = w
+}
+
+func (m *QueueMonitor) get(q engine.QueueInterface) *queueOccupancy {
+	o, ok := m.queues[q]
+	if !ok {
+		o = &queueOccupancy{q: q, last: m.window.Start}
+		m.queues[q] = o
+		m.order = append(m.order, o)
+	}
+	return o
+}
+
+// advance integrates the queue length till now. The length counts for the
+// maximum too, as the queue held it in the window, maybe since before the
+// window opened
+func (m *QueueMonitor) advance(o *queueOccupancy, now float64) {
+	end := math.Min(now, m.window.End)
+	if end > o.last {
+		o.area += float64(o.length) * (end - math.Max(o.last, m.window.Start))
+		o.last = end
+		if o.length > o.maxLen {
+			o.maxLen = o.length
+		}
+	}
+}
+
+// Enqueued accounts a request entering a queue
+func (m *QueueMonitor) Enqueued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	o := m.get(q)
+	m.advance(o, now)
+	o.length++
+	o.arrivals = append(o.arrivals, now)
+	if m.window.Contains(now) {
+		o.enqueued++
+		if o.length > o.maxLen {
+			o.maxLen = o.length
+		}
+	}
+}
+
+// Dequeued accounts a request leaving a queue
+func (m *QueueMonitor) Dequeued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	o := m.get(q)
+	m.advance(o, now)
+	if o.length == 0 {
+		// enqueued before the monitor was added
+		return
+	}
+	o.length--
+	arrival := o.arrivals[0]
+	o.arrivals = o.arrivals[1:]
+	if m.window.Contains(now) {
+		o.dequeued++
+		o.sojourn += now - arrival
+	}
+}
+
+// PrintStats prints the occupancy statistics of every queue. The sojourn
+// is n/a for a queue with no dequeue in the window
+func (m *QueueMonitor) PrintStats(now float64) {
+	elapsed := m.window.Length(now)
+	fmt.Println("Queue occupancy")
+	fmt.Println("Queue\tAvgLen\tMaxLen\tEnqueued\tDequeued\tAvgSojourn")
+	for _, o := range m.order {
+		m.advance(o, now)
+		sojourn := "n/a"
+		if o.dequeued > 0 {
+			sojourn = fmt.Sprint(o.sojourn / float64(o.dequeued))
+		}
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", o.q, o.area/elapsed, o.maxLen,
+			o.enqueued, o.dequeued, sojourn)
+	}
+}
@@ -16,23 +16,75 @@ const (
 // RequestDrain describes the behaviour of a the element that receives a request
 // after processor serving and is in charge of keeping the statistics
 type RequestDrain interface {
-	TerminateReq(r engine.ReqInterface)
+	TerminateReq(r engine.ReqInterface, now float64)
 	SetName(name string)
 }
 
+// windowFilter keeps the measurement window of a statistics collector.
+// All collectors should have it as an embedded field
+type windowFilter struct {
+	window    engine.Window
+	byArrival bool
+}
+
+// SetWindow sets the measurement window. It is called by the simulation
+func (f *windowFilter) SetWindow(w engine.Window) {
+	f.window = w
+}
+
+// SetFilterByArrival makes the collector keep the requests that arrived
+// in the measurement window, instead of the ones that finished in it
+func (f *windowFilter) SetFilterByArrival(byArrival bool) {
+	f.byArrival = byArrival
+}
+
+// inWindow returns whether a request finishing at now should be accounted
+func (f *windowFilter) inWindow(req engine.ReqInterface, now float64) bool {
+	if f.byArrival {
+		return f.window.Contains(now - req.GetDelay(now))
+	}
+	return f.window.Contains(now)
+}
+
+func (f *windowFilter) printWindow(now float64) {
+	fmt.Printf("Window: [%v, %v]\n", f.window.Start, math.Min(f.window.End, now))
+}
+
 // AllKeeper implements the RequestDrain interface and caclulates statistics
 // on all the given requests, without sampling
 type AllKeeper struct {
+	windowFilter
 	items       []float64
 	name        string
 	stolenCount int
+	batches     *BatchMeans
+	byPhase     map[int][]float64
+	bySource    map[int][]float64
 }
 
 // TerminateReq is the function called by the processor after finishing
 // request processing
-func (k *AllKeeper) TerminateReq(req engine.ReqInterface) {
-	d := req.GetDelay()
+func (k *AllKeeper) TerminateReq(req engine.ReqInterface, now float64) {
+	if !k.inWindow(req, now) {
+		return
+	}
+	d := req.GetDelay(now)
 	k.items = append(k.items, d)
+	if k.batches != nil {
+		k.batches.Add(d)
+	}
+	if k.byPhase != nil {
+		if tagged, ok := req.(LoadPhaseReq); ok {
+			p := tagged.GetLoadPhase()
+			k.byPhase[p] = append(k.byPhase[p], d)
+		}
+	}
+	if k.bySource != nil {
+		if tagged, ok := req.(SourceReq); ok {
+			s := tagged.GetSource()
+			k.bySource[s] = append(k.bySource[s], d)
+		}
+	}
 	if stealable, ok := req.(*StealableReq); ok {
 		if stealable.stolen {
 			k.stolenCount++
@@ -45,6 +97,36 @@ func (k *AllKeeper) SetName(name string) {
 	k.name = name
 }
 
+// SetBatchMeans makes the AllKeeper feed every accounted latency to b
+func (k *AllKeeper) SetBatchMeans(b *BatchMeans) {
+	k.batches = b
+}
+
+// SetByLoadPhase makes the AllKeeper also report the latency of the
+// requests of every load phase
+func (k *AllKeeper) SetByLoadPhase(byPhase bool) {
+	if byPhase {
+		k.byPhase = make(map[int][]float64)
+	} else {
+		k.byPhase = nil
+	}
+}
+
+// SetBySource makes the AllKeeper also report the latency of the requests
+// of every generator
+func (k *AllKeeper) SetBySource(bySource bool) {
+	if bySource {
+		k.bySource = make(map[int][]float64)
+	} else {
+		k.bySource = nil
+	}
+}
+
+// Count returns the number of requests accounted for
+func (k *AllKeeper) Count() int {
+	return len(k.items)
+}
+
 func (k *AllKeeper) avg() float64 {
 	tmp := 0.0
 	for _, v := range k.items {
@@ -62,19 +144,49 @@ func (k *AllKeeper) std() float64 {
 }
 
 func (k *AllKeeper) getPercentiles() map[float64]float64 {
+	return getPercentiles(k.items)
+}
+
+// getPercentiles sorts items and returns their percentiles
+func getPercentiles(items []float64) map[float64]float64 {
 	res := make(map[float64]float64)
-	sort.Float64s(k.items)
+	sort.Float64s(items)
 	for _, v := range []float64{0.5, 0.9, 0.95, 0.99} {
-		idx := int(float64(len(k.items)) * v)
-		res[v] = k.items[idx]
+		idx := int(float64(len(items)) * v)
+		res[v] = items[idx]
 	}
 	return res
 }
 
+// printGroups prints the latency of the requests of every group, e.g. load
+// phase or source
+func printGroups(name string, groups map[int][]float64) {
+	ids := make([]int, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fmt.Printf("%v\tCount\tAVG\t50th\t90th\t95th\t99th\n", name)
+	for _, id := range ids {
+		items := groups[id]
+		sum := 0.0
+		for _, v := range items {
+			sum += v
+		}
+		fmt.Printf("%v\t%v\t%v", id, len(items), sum/float64(len(items)))
+		percentiles := getPercentiles(items)
+		for _, v := range []float64{0.5, 0.9, 0.95, 0.99} {
+			fmt.Printf("\t%v", percentiles[v])
+		}
+		fmt.Println()
+	}
+}
+
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (k *AllKeeper) PrintStats() {
+func (k *AllKeeper) PrintStats(now float64) {
 	fmt.Printf("Stats collector: %v\n", k.name)
+	k.printWindow(now)
 	fmt.Printf("Count\tStolen\tAVG\tSTDDev\t50th\t90th\t95th\t99th\tReqs/time_unit\n")
 	fmt.Printf("%v\t%v\t%v\t%v\t", len(k.items), k.stolenCount, k.avg(), k.std())
 
@@ -85,11 +197,21 @@ func (k *AllKeeper) PrintStats() {
 			fmt.Printf("%v\t", percentiles[v])
 		}
 	}
-	fmt.Printf("%v\n", float64(len(k.items))/engine.GetTime())
+	fmt.Printf("%v\n", float64(len(k.items))/k.window.Length(now))
+	if k.batches != nil {
+		k.batches.printCI()
+	}
+	if k.byPhase != nil {
+		printGroups("Load phase", k.byPhase)
+	}
+	if k.bySource != nil {
+		printGroups("Source", k.bySource)
+	}
 }
 
 // MonitorKeeper keeps statistics about queue lengths
 type MonitorKeeper struct {
+	windowFilter
 	delays   []float64
 	initLen  []int
 	finalLen []int
@@ -98,8 +220,11 @@ type MonitorKeeper struct {
 
 // TerminateReq is the function called by the processor after finishing
 // request processing
-func (k *MonitorKeeper) TerminateReq(req engine.ReqInterface) {
-	k.delays = append(k.delays, req.GetDelay())
+func (k *MonitorKeeper) TerminateReq(req engine.ReqInterface, now float64) {
+	if !k.inWindow(req, now) {
+		return
+	}
+	k.delays = append(k.delays, req.GetDelay(now))
 
 	if monitorReq, ok := req.(*MonitorReq); ok {
 		k.initLen = append(k.initLen, monitorReq.getInitLen())
@@ -109,7 +234,8 @@ func (k *MonitorKeeper) TerminateReq(req engine.ReqInterface) {
 
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (k *MonitorKeeper) PrintStats() {
+func (k *MonitorKeeper) PrintStats(now float64) {
+	k.printWindow(now)
 	fmt.Println("#Latency\tEntrace Queue\tExit Queue")
 	for idx, d := range k.delays {
 		fmt.Printf("%v\t%v\t%v\n", d, k.initLen[idx], k.finalLen[idx])
@@ -206,7 +332,7 @@ func (hdr *histogram) getPercentiles() map[float64]float64 {
 	return res
 }
 
-func (hdr *histogram) printPercentiles() {
+func (hdr *histogram) printPercentiles(elapsed float64) {
 	percentiles := hdr.getPercentiles()
 	vals := []float64{0.5, 0.9, 0.95, 0.99}
 	for _, v := range vals {
@@ -214,11 +340,12 @@ func (hdr *histogram) printPercentiles() {
 	}
 	fmt.Println()
 
-	fmt.Printf("Req/time_unit:%v\n", float64(hdr.count)/engine.GetTime())
+	fmt.Printf("Req/time_unit:%v\n", float64(hdr.count)/elapsed)
 }
 
 // BookKeeper uses buckets to keep the information
 type BookKeeper struct {
+	windowFilter
 	hdr  *histogram
 	name string
 }
@@ -235,17 +362,26 @@ func (b *BookKeeper) SetName(name string) {
 	b.name = name
 }
 
+// Count returns the number of requests accounted for
+func (b *BookKeeper) Count() int {
+	return int(b.hdr.count)
+}
+
 // TerminateReq is the function called by the processor after finishing
 // request processing
-func (b *BookKeeper) TerminateReq(req engine.ReqInterface) {
-	d := req.GetDelay()
+func (b *BookKeeper) TerminateReq(req engine.ReqInterface, now float64) {
+	if !b.inWindow(req, now) {
+		return
+	}
+	d := req.GetDelay(now)
 	b.hdr.addSample(d)
 }
 
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (b *BookKeeper) PrintStats() {
+func (b *BookKeeper) PrintStats(now float64) {
 	fmt.Printf("Stats collector: %v\n", b.name)
+	b.printWindow(now)
 	fmt.Printf("Count\tAVG\tSTDDev\t50th\t90th\t95th\t99th Reqs/time_unit\n")
 	fmt.Printf("%v\t%v\t%v\t", b.hdr.count, b.hdr.avg(), b.hdr.stddev())
 
@@ -254,5 +390,5 @@ func (b *BookKeeper) PrintStats() {
 	for _, v := range vals {
 		fmt.Printf("%v\t", percentiles[v])
 	}
-	fmt.Printf("%v\n", float64(b.hdr.count)/engine.GetTime())
+	fmt.Printf("%v\n", float64(b.hdr.count)/b.window.Length(now))
 }
@@ -10,12 +10,14 @@ import (
 type Request struct {
 	InitTime    float64
 	ServiceTime float64
+	LoadPhase   int
+	Source      int
 }
 
 // GetDelay returns the request latency from the time it was sent till the time
 // processing was over
-func (r Request) GetDelay() float64 {
-	return engine.GetTime() - r.InitTime
+func (r Request) GetDelay(now float64) float64 {
+	return now - r.InitTime
 }
 
 // GetServiceTime returns the request service time
@@ -28,6 +30,39 @@ func (r *Request) SubServiceTime(t float64) {
 	r.ServiceTime -= t
 }
 
+// SetLoadPhase tags the request with the load phase it arrived in
+func (r *Request) SetLoadPhase(phase int) {
+	r.LoadPhase = phase
+}
+
+// GetLoadPhase returns the load phase the request arrived in
+func (r Request) GetLoadPhase() int {
+	return r.LoadPhase
+}
+
+// LoadPhaseReq is a request that can be tagged with its load phase
+type LoadPhaseReq interface {
+	SetLoadPhase(phase int)
+	GetLoadPhase() int
+}
+
+// SetSource tags the request with the id of the generator that created it
+func (r *Request) SetSource(source int) {
+	r.Source = source
+}
+
+// GetSource returns the id of the generator that created the request
+func (r Request) GetSource() int {
+	return r.Source
+}
+
+// SourceReq is a request that can be tagged with the generator that
+// created it
+type SourceReq interface {
+	SetSource(source int)
+	GetSource() int
+}
+
 // StealableReq is a request that can be stolen and is used to account for steals
 type StealableReq struct {
 	Request
@@ -56,35 +91,53 @@ type ColoredReq struct {
 
 // ReqCreator is a used by generators to create the appropriate type of requests
 type ReqCreator interface {
-	NewRequest(serviceTime float64) engine.ReqInterface
+	NewRequest(now, serviceTime float64) engine.ReqInterface
+}
+
+// RandReqCreator is a ReqCreator with random draws of its own. The
+// generator owning it gives it random streams derived from the generator
+// seed before it creates the first request
+type RandReqCreator interface {
+	ReqCreator
+	InitRand(newRand func() *rand.Rand)
 }
 
 // SimpleReqCreator creates structs of type Request
 type SimpleReqCreator struct{}
 
 // NewRequest returns a new Request struct
-func (rc SimpleReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}
+func (rc SimpleReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
+	return &Request{InitTime: now, ServiceTime: serviceTime}
 }
 
 // StealableReqCreator creates structs of type StealableReq
 type StealableReqCreator struct{}
 
 // NewRequest returns a new StealableReq struct
-func (rc StealableReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &StealableReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, false}
+func (rc StealableReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
+	return &StealableReq{Request{InitTime: now, ServiceTime: serviceTime}, false}
 }
 
 // MonitorReqCreator creates structs of type MonitorReq
 type MonitorReqCreator struct{}
 
 // NewRequest returns a new MonitorReq struct
-func (rc MonitorReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &MonitorReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, 0, 0}
+func (rc MonitorReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
+	return &MonitorReq{Request{InitTime: now, ServiceTime: serviceTime}, 0, 0}
+}
+
+// ColoredReqCreator creates structs of type ColoredReq with a random color
+type ColoredReqCreator struct {
+	rng *rand.Rand
 }
 
-type ColoredReqCreator struct{}
+// NewColoredReqCreator returns a ColoredReqCreator drawing colors from the
+// given random stream
+func NewColoredReqCreator(r *rand.Rand) *ColoredReqCreator {
+	return &ColoredReqCreator{rng: r}
+}
 
-func (rc ColoredReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &ColoredReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, rand.Int() % 2}
+// NewRequest returns a new ColoredReq struct
+func (rc ColoredReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
+	return &ColoredReq{Request{InitTime: now, ServiceTime: serviceTime}, rc.rng.Int() % 2}
 }
@@ -0,0 +1,103 @@
+package blocks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// idActor is an actor that knows its simulation id
+type idActor interface {
+	GetID() int
+}
+
+// Sampler is an actor that wakes up every interval and records the length
+// of the watched queues, the requests counted by a Counter since the last
+// sample and whether the watched actors are busy. The samples are written as
+// CSV at the end of the simulation, so it should be registered with both
+// RegisterActor and InitStats. It is stopped with the generators when the
+// simulation drains
+type Sampler struct {
+	engine.Actor
+	interval   float64
+	counter    Counter
+	w          io.Writer
+	queues     []engine.QueueInterface
+	actors     []idActor
+	actorNames []string
+	rows       [][]string
+}
+
+// NewSampler returns a new *Sampler that samples every interval, counts the
+// completions of counter and writes the samples to w
+func NewSampler(interval float64, counter Counter, w io.Writer) *Sampler {
+	return &Sampler{interval: interval, counter: counter, w: w}
+}
+
+// WatchQueue adds queues whose length is sampled
+func (s *Sampler) WatchQueue(qs ...engine.QueueInterface) {
+	s.queues = append(s.queues, qs...)
+}
+
+// WatchActor adds an actor whose busy state is sampled, named by name in the
+// CSV header
+func (s *Sampler) WatchActor(name string, a idActor) {
+	s.actors = append(s.actors, a)
+	s.actorNames = append(s.actorNames, name)
+}
+
+// IsGenerator makes the sampler stop when the simulation drains
+func (s *Sampler) IsGenerator() bool {
+	return true
+}
+
+// IsMonitor lets the simulation end without waiting for the sampler
+func (s *Sampler) IsMonitor() bool {
+	return true
+}
+
+// SetWindow does nothing. The samples cover the whole simulation
+func (s *Sampler) SetWindow(w engine.Window) {}
+
+// Run is the main loop of the sampler
+func (s *Sampler) Run() {
+	last := 0
+	for {
+		s.Wait(s.interval)
+		row := []string{strconv.FormatFloat(s.GetTime(), 'g', -1, 64)}
+		for _, q := range s.queues {
+			row = append(row, strconv.Itoa(q.Len()))
+		}
+		count := s.counter.Count()
+		row = append(row, strconv.Itoa(count-last))
+		last = count
+		for _, a := range s.actors {
+			busy := "0"
+			if s.GetSim().IsBusy(a.GetID()) {
+				busy = "1"
+			}
+			row = append(row, busy)
+		}
+		s.rows = append(s.rows, row)
+	}
+}
+
+// PrintStats writes the samples as CSV
+func (s *Sampler) PrintStats(now float64) {
+	cw := csv.NewWriter(s.w)
+	header := []string{"time"}
+	for _, q := range s.queues {
+		header = append(header, fmt.Sprint(q))
+	}
+	header = append(header, "completions")
+	header = append(header, s.actorNames...)
+	cw.Write(header)
+	cw.WriteAll(s.rows)
+	if err := cw.Error(); err != nil {
+		log.Printf("sampler: %v", err)
+	}
+}
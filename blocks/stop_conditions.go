@@ -0,0 +1,148 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Counter is implemented by the statistics collectors that count the
+// requests they accounted for
+type Counter interface {
+	Count() int
+}
+
+// CountStop is a stop condition that ends the simulation once a collector
+// has counted n requests
+type CountStop struct {
+	k Counter
+	n int
+}
+
+// NewCountStop returns a new *CountStop
+func NewCountStop(k Counter, n int) *CountStop {
+	return &CountStop{k: k, n: n}
+}
+
+// Done returns true when the collector has counted enough requests
+func (c *CountStop) Done() bool {
+	return c.k.Count() >= c.n
+}
+
+// tTable keeps the 0.975 quantiles of the Student t distribution for
+// 1 to 30 degrees of freedom
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile returns the 0.975 quantile of the Student t distribution
+func tQuantile(df int) float64 {
+	if df <= len(tTable) {
+		return tTable[df-1]
+	}
+	return 1.96
+}
+
+// BatchMeans splits the request latencies in fixed size batches and keeps
+// the mean and 99th percentile of every batch. The batch statistics are
+// used to estimate 95% confidence intervals
+type BatchMeans struct {
+	batchSize int
+	current   []float64
+	means     []float64
+	p99s      []float64
+}
+
+// NewBatchMeans returns a new *BatchMeans with batches of batchSize requests
+func NewBatchMeans(batchSize int) *BatchMeans {
+	return &BatchMeans{batchSize: batchSize, current: make([]float64, 0, batchSize)}
+}
+
+// Add adds a latency sample to the current batch
+func (b *BatchMeans) Add(d float64) {
+	b.current = append(b.current, d)
+	if len(b.current) < b.batchSize {
+		return
+	}
+	sum := 0.0
+	for _, v := range b.current {
+		sum += v
+	}
+	b.means = append(b.means, sum/float64(len(b.current)))
+	sort.Float64s(b.current)
+	b.p99s = append(b.p99s, b.current[int(float64(len(b.current))*0.99)])
+	b.current = b.current[:0]
+}
+
+// Batches returns the number of complete batches
+func (b *BatchMeans) Batches() int {
+	return len(b.means)
+}
+
+// relHalfWidth returns the relative half-width of the 95% confidence
+// interval of the mean of the given batch statistics
+func relHalfWidth(vals []float64) float64 {
+	k := len(vals)
+	if k < 2 {
+		return math.Inf(1)
+	}
+	mean := 0.0
+	for _, v := range vals {
+		mean += v
+	}
+	mean /= float64(k)
+	variance := 0.0
+	for _, v := range vals {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(k - 1)
+	return tQuantile(k-1) * math.Sqrt(variance/float64(k)) / math.Abs(mean)
+}
+
+// MeanRelHalfWidth returns the relative half-width of the mean latency
+// confidence interval
+func (b *BatchMeans) MeanRelHalfWidth() float64 {
+	return relHalfWidth(b.means)
+}
+
+// P99RelHalfWidth returns the relative half-width of the 99th percentile
+// latency confidence interval
+func (b *BatchMeans) P99RelHalfWidth() float64 {
+	return relHalfWidth(b.p99s)
+}
+
+func (b *BatchMeans) printCI() {
+	fmt.Printf("Batches: %v\tMean CI95: +-%.4f%%\t99th CI95: +-%.4f%%\n",
+		b.Batches(), 100*b.MeanRelHalfWidth(), 100*b.P99RelHalfWidth())
+}
+
+// CIStop is a stop condition that ends the simulation once the relative
+// half-width of the 95% confidence intervals of both the mean and the 99th
+// percentile latency drop below a target
+type CIStop struct {
+	b          *BatchMeans
+	target     float64
+	minBatches int
+	checked    int
+	done       bool
+}
+
+// NewCIStop returns a new *CIStop. The intervals are only checked after
+// minBatches batches are complete
+func NewCIStop(b *BatchMeans, target float64, minBatches int) *CIStop {
+	return &CIStop{b: b, target: target, minBatches: minBatches}
+}
+
+// Done returns true when both confidence intervals are narrow enough.
+// The intervals are only recomputed when a new batch completes
+func (c *CIStop) Done() bool {
+	k := c.b.Batches()
+	if k == c.checked || k < c.minBatches {
+		return c.done
+	}
+	c.checked = k
+	c.done = c.b.MeanRelHalfWidth() <= c.target && c.b.P99RelHalfWidth() <= c.target
+	return c.done
+}
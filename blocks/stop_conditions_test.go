@@ -0,0 +1,75 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+)
+
+func TestBatchMeans(t *testing.T) {
+	alternating := make([]float64, 40)
+	for i := range alternating {
+		alternating[i] = float64(1 + 2*(i%2))
+	}
+	tests := []struct {
+		name      string
+		batchSize int
+		samples   []float64
+		batches   int
+		mean      float64
+		p99       float64
+	}{
+		{"no batch", 3, []float64{1, 2}, 0, math.Inf(1), math.Inf(1)},
+		{"one batch", 2, []float64{1, 3, 5}, 1, math.Inf(1), math.Inf(1)},
+		// batch means 2, 6, 10 and 99th percentiles 3, 7, 11, both with
+		// standard deviation 4 over 3 batches: t(2) * 4/sqrt(3) / mean
+		{"three batches", 2, []float64{1, 3, 5, 7, 9, 11}, 3, 4.303 * 4 / math.Sqrt(3) / 6, 4.303 * 4 / math.Sqrt(3) / 7},
+		{"equal batches", 2, []float64{1, 3, 3, 1, 1, 3}, 3, 0, 0},
+		// past 30 degrees of freedom the normal quantile is used
+		{"normal quantile", 1, alternating, 40, 1.96 * math.Sqrt(40.0/39/40) / 2, 1.96 * math.Sqrt(40.0/39/40) / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := blocks.NewBatchMeans(tt.batchSize)
+			for _, s := range tt.samples {
+				b.Add(s)
+			}
+			if b.Batches() != tt.batches {
+				t.Errorf("%v batches, want %v", b.Batches(), tt.batches)
+			}
+			if got := b.MeanRelHalfWidth(); !approx(got, tt.mean) {
+				t.Errorf("mean relative half-width %v, want %v", got, tt.mean)
+			}
+			if got := b.P99RelHalfWidth(); !approx(got, tt.p99) {
+				t.Errorf("99th relative half-width %v, want %v", got, tt.p99)
+			}
+		})
+	}
+}
+
+func TestCIStop(t *testing.T) {
+	b := blocks.NewBatchMeans(1)
+	c := blocks.NewCIStop(b, 0.1, 4)
+	for _, s := range []float64{10, 10, 10} {
+		b.Add(s)
+	}
+	if c.Done() {
+		t.Error("done before the minimum number of batches")
+	}
+	b.Add(10)
+	if !c.Done() {
+		t.Error("not done with equal batches")
+	}
+	b.Add(100)
+	if c.Done() {
+		t.Error("done with a wide interval")
+	}
+}
+
+func approx(got, want float64) bool {
+	if math.IsInf(want, 1) {
+		return math.IsInf(got, 1)
+	}
+	return math.Abs(got-want) < 1e-9
+}
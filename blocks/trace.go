@@ -0,0 +1,259 @@
+package blocks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// TracePhase is a phase of a traced request: its service time and the
+// devices that can run it
+type TracePhase struct {
+	Service float64
+	Devices []int
+}
+
+// TraceRecord is a request of a trace
+type TraceRecord struct {
+	Arrival float64
+	Class   int
+	Phases  []TracePhase
+}
+
+// traceJSON is a line of a JSONL trace
+type traceJSON struct {
+	Arrival *float64 `json:"arrival"`
+	Class   int      `json:"class"`
+	Phases  []struct {
+		Service *float64 `json:"service"`
+		Devices []string `json:"devices"`
+	} `json:"phases"`
+}
+
+// LoadTrace reads a request trace. Files ending in .jsonl have a JSON
+// object per line:
+//
+//	{"arrival": 0.5, "class": 1, "phases": [{"service": 2, "devices": ["cpu"]}, ...]}
+//
+// Other files are CSV with a line per request: the arrival time, the class
+// and a service time and device list per phase, where devices are
+// separated by |:
+//
+//	0.5,1,2,cpu,10,cpu|acc,1,cpu
+//
+// A non-numeric first CSV line is taken as a header. devices maps the
+// device names of the trace to device ids. Arrival times should not
+// decrease. Malformed lines are reported with their line number
+func LoadTrace(path string, devices map[string]int) ([]TraceRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	parse := parseTraceCSV
+	if strings.HasSuffix(path, ".jsonl") {
+		parse = parseTraceJSON
+	}
+
+	var records []TraceRecord
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		rec, err := parse(text, devices)
+		if err != nil {
+			if line == 1 && !strings.HasSuffix(path, ".jsonl") {
+				if _, numErr := strconv.ParseFloat(strings.Split(text, ",")[0], 64); numErr != nil {
+					// header
+					continue
+				}
+			}
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		if len(records) > 0 && rec.Arrival < records[len(records)-1].Arrival {
+			return nil, fmt.Errorf("%v:%v: arrival time %v before the previous one", path, line, rec.Arrival)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%v: empty trace", path)
+	}
+	return records, nil
+}
+
+func parseDevices(names []string, devices map[string]int) ([]int, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("phase with no devices")
+	}
+	ids := make([]int, 0, len(names))
+	for _, n := range names {
+		id, ok := devices[strings.TrimSpace(n)]
+		if !ok {
+			return nil, fmt.Errorf("unknown device %q", n)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseTraceCSV(text string, devices map[string]int) (TraceRecord, error) {
+	var rec TraceRecord
+	fields := strings.Split(text, ",")
+	if len(fields) < 4 || len(fields)%2 != 0 {
+		return rec, fmt.Errorf("expected arrival, class and service, devices pairs, got %v fields", len(fields))
+	}
+	var err error
+	if rec.Arrival, err = strconv.ParseFloat(strings.TrimSpace(fields[0]), 64); err != nil {
+		return rec, fmt.Errorf("arrival: %v", err)
+	}
+	if rec.Class, err = strconv.Atoi(strings.TrimSpace(fields[1])); err != nil {
+		return rec, fmt.Errorf("class: %v", err)
+	}
+	for i := 2; i < len(fields); i += 2 {
+		var p TracePhase
+		if p.Service, err = strconv.ParseFloat(strings.TrimSpace(fields[i]), 64); err != nil {
+			return rec, fmt.Errorf("phase %v service: %v", len(rec.Phases), err)
+		}
+		if p.Service < 0 {
+			return rec, fmt.Errorf("phase %v: negative service time", len(rec.Phases))
+		}
+		if p.Devices, err = parseDevices(strings.Split(fields[i+1], "|"), devices); err != nil {
+			return rec, fmt.Errorf("phase %v: %v", len(rec.Phases), err)
+		}
+		rec.Phases = append(rec.Phases, p)
+	}
+	return rec, nil
+}
+
+func parseTraceJSON(text string, devices map[string]int) (TraceRecord, error) {
+	var rec TraceRecord
+	var j traceJSON
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return rec, err
+	}
+	if j.Arrival == nil {
+		return rec, fmt.Errorf("missing arrival")
+	}
+	if len(j.Phases) == 0 {
+		return rec, fmt.Errorf("no phases")
+	}
+	rec.Arrival, rec.Class = *j.Arrival, j.Class
+	for i, jp := range j.Phases {
+		if jp.Service == nil || *jp.Service < 0 {
+			return rec, fmt.Errorf("phase %v: missing or negative service time", i)
+		}
+		ids, err := parseDevices(jp.Devices, devices)
+		if err != nil {
+			return rec, fmt.Errorf("phase %v: %v", i, err)
+		}
+		rec.Phases = append(rec.Phases, TracePhase{Service: *jp.Service, Devices: ids})
+	}
+	return rec, nil
+}
+
+// TraceReqCreator creates the requests of a trace
+type TraceReqCreator interface {
+	NewTraceRequest(now float64, rec TraceRecord) engine.ReqInterface
+}
+
+// TraceGenerator replays a trace, emitting every request at its recorded
+// arrival time times the time scale. If looping, the trace starts over
+// after its last arrival plus the mean interarrival time
+type TraceGenerator struct {
+	engine.Actor
+	requestSource
+	records  []TraceRecord
+	creator  TraceReqCreator
+	dispatch DispatchPolicy
+	scale    float64
+	loop     bool
+}
+
+// NewTraceGenerator returns a new *TraceGenerator
+func NewTraceGenerator(records []TraceRecord, creator TraceReqCreator, dispatch DispatchPolicy) *TraceGenerator {
+	return &TraceGenerator{records: records, creator: creator, dispatch: dispatch, scale: 1}
+}
+
+// SetTimeScale multiplies the arrival times of the trace by scale, which
+// should be positive
+func (g *TraceGenerator) SetTimeScale(scale float64) error {
+	if scale <= 0 {
+		return fmt.Errorf("trace time scale %v is not positive", scale)
+	}
+	g.scale = scale
+	return nil
+}
+
+// SetLoop makes the generator replay the trace forever. A trace whose
+// arrivals are all at time 0 has no period and cannot loop
+func (g *TraceGenerator) SetLoop(loop bool) error {
+	if loop && g.period() <= 0 {
+		return fmt.Errorf("trace with every arrival at time 0 cannot loop")
+	}
+	g.loop = loop
+	return nil
+}
+
+// CheckDispatch returns an error if the dispatch policy cannot feed the out
+// queues of the generator. It should be called once the out queues are
+// added
+func (g *TraceGenerator) CheckDispatch() error {
+	return checkDispatch(g.dispatch, g.GetOutQueueCount())
+}
+
+// IsGenerator marks the actor as a request source for the simulation
+func (g *TraceGenerator) IsGenerator() bool {
+	return true
+}
+
+// period returns the time between the starts of two loops of the trace
+func (g *TraceGenerator) period() float64 {
+	first, last := g.records[0].Arrival, g.records[len(g.records)-1].Arrival
+	// the mean interarrival time, counted from 0 if every arrival is at
+	// the same time
+	gap := last
+	if last > first {
+		gap = (last - first) / float64(len(g.records)-1)
+	}
+	return (last - first + gap) * g.scale
+}
+
+// Run is the main loop of the generator. It returns at the end of the
+// trace if not looping, or once the generator is exhausted
+func (g *TraceGenerator) Run() {
+	if err := g.CheckDispatch(); err != nil {
+		panic(err)
+	}
+	g.dispatch.SetRand(g.Rand())
+	period := g.period()
+	for offset := 0.0; ; offset += period {
+		for _, rec := range g.records {
+			if d := offset + rec.Arrival*g.scale - g.GetTime(); d > 0 {
+				g.Wait(d)
+			}
+			req := g.creator.NewTraceRequest(g.GetTime(), rec)
+			g.tag(req)
+			g.ReportCreation(req)
+			g.WriteOutQueueI(req, g.dispatch.Select(g.GetOutQueues(), req, g.GetTime()))
+			if g.Exhausted() {
+				return
+			}
+		}
+		if !g.loop {
+			return
+		}
+	}
+}
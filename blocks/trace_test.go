@@ -0,0 +1,183 @@
+package blocks_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+func TestLoadTrace(t *testing.T) {
+	devices := map[string]int{"cpu": 0, "acc": 1}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []blocks.TraceRecord
+		err     string // a substring of the error, if the trace is malformed
+	}{
+		{
+			name:    "csv",
+			file:    "trace.csv",
+			content: "0.5,1,2,cpu,10,cpu|acc,1,cpu\n\n3,0,4,acc\n",
+			want: []blocks.TraceRecord{
+				{Arrival: 0.5, Class: 1, Phases: []blocks.TracePhase{{2, []int{0}}, {10, []int{0, 1}}, {1, []int{0}}}},
+				{Arrival: 3, Class: 0, Phases: []blocks.TracePhase{{4, []int{1}}}},
+			},
+		},
+		{
+			name:    "csv header",
+			file:    "trace.csv",
+			content: "arrival,class,service,devices\n1,2,3,cpu\n",
+			want:    []blocks.TraceRecord{{Arrival: 1, Class: 2, Phases: []blocks.TracePhase{{3, []int{0}}}}},
+		},
+		{
+			name:    "csv equal arrivals",
+			file:    "trace.csv",
+			content: "1,0,1,cpu\n1,1,2,acc\n",
+			want: []blocks.TraceRecord{
+				{Arrival: 1, Class: 0, Phases: []blocks.TracePhase{{1, []int{0}}}},
+				{Arrival: 1, Class: 1, Phases: []blocks.TracePhase{{2, []int{1}}}},
+			},
+		},
+		{
+			name: "jsonl",
+			file: "trace.jsonl",
+			content: `{"arrival": 0.5, "class": 1, "phases": [{"service": 2, "devices": ["cpu"]}, {"service": 10, "devices": ["cpu", "acc"]}]}` + "\n" +
+				`{"arrival": 2, "phases": [{"service": 0, "devices": ["acc"]}]}` + "\n",
+			want: []blocks.TraceRecord{
+				{Arrival: 0.5, Class: 1, Phases: []blocks.TracePhase{{2, []int{0}}, {10, []int{0, 1}}}},
+				{Arrival: 2, Class: 0, Phases: []blocks.TracePhase{{0, []int{1}}}},
+			},
+		},
+		{name: "empty", file: "trace.csv", content: "\n", err: "empty trace"},
+		{name: "header only", file: "trace.csv", content: "arrival,class,service,devices\n", err: "empty trace"},
+		{name: "odd fields", file: "trace.csv", content: "0,0,1,cpu,2\n", err: "trace.csv:1: expected arrival"},
+		{name: "bad arrival", file: "trace.csv", content: "0,0,1,cpu\nx,0,1,cpu\n", err: "trace.csv:2: arrival"},
+		{name: "bad class", file: "trace.csv", content: "0,x,1,cpu\n", err: "trace.csv:1: class"},
+		{name: "bad service", file: "trace.csv", content: "0,0,1,cpu,x,cpu\n", err: "trace.csv:1: phase 1 service"},
+		{name: "negative service", file: "trace.csv", content: "0,0,-1,cpu\n", err: "trace.csv:1: phase 0: negative service time"},
+		{name: "unknown device", file: "trace.csv", content: "0,0,1,gpu\n", err: `trace.csv:1: phase 0: unknown device "gpu"`},
+		{name: "decreasing arrivals", file: "trace.csv", content: "2,0,1,cpu\n1,0,1,cpu\n", err: "trace.csv:2: arrival time 1 before the previous one"},
+		{name: "json missing arrival", file: "trace.jsonl", content: `{"phases": [{"service": 1, "devices": ["cpu"]}]}`, err: "trace.jsonl:1: missing arrival"},
+		{name: "json no phases", file: "trace.jsonl", content: `{"arrival": 0}`, err: "trace.jsonl:1: no phases"},
+		{name: "json missing service", file: "trace.jsonl", content: `{"arrival": 0, "phases": [{"devices": ["cpu"]}]}`, err: "trace.jsonl:1: phase 0: missing or negative service time"},
+		{name: "json no devices", file: "trace.jsonl", content: `{"arrival": 0, "phases": [{"service": 1}]}`, err: "trace.jsonl:1: phase 0: phase with no devices"},
+		{name: "json unknown field", file: "trace.jsonl", content: `{"arrival": 0, "size": 1}`, err: "trace.jsonl:1: json: unknown field"},
+		{name: "json header", file: "trace.jsonl", content: "arrival\n", err: "trace.jsonl:1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := blocks.LoadTrace(path, devices)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceSetLoop(t *testing.T) {
+	rec := func(arrival float64) blocks.TraceRecord {
+		return blocks.TraceRecord{Arrival: arrival, Phases: []blocks.TracePhase{{1, []int{0}}}}
+	}
+	tests := []struct {
+		name    string
+		records []blocks.TraceRecord
+		wantErr bool
+	}{
+		{"one at 0", []blocks.TraceRecord{rec(0)}, true},
+		{"all at 0", []blocks.TraceRecord{rec(0), rec(0)}, true},
+		{"one later", []blocks.TraceRecord{rec(2)}, false},
+		{"spread", []blocks.TraceRecord{rec(0), rec(1)}, false},
+	}
+	for _, tt := range tests {
+		g := blocks.NewTraceGenerator(tt.records, nil, &blocks.RoundRobinDispatch{})
+		if err := g.SetLoop(true); (err != nil) != tt.wantErr {
+			t.Errorf("%v: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err := g.SetLoop(false); err != nil {
+			t.Errorf("%v: not looping: %v", tt.name, err)
+		}
+	}
+}
+
+// traceCreator creates a Request per trace record
+type traceCreator struct{}
+
+func (traceCreator) NewTraceRequest(now float64, rec blocks.TraceRecord) engine.ReqInterface {
+	return &blocks.Request{InitTime: now}
+}
+
+// arrivals keeps the creation time of the finished requests
+type arrivals struct {
+	times []float64
+}
+
+func (a *arrivals) TerminateReq(req engine.ReqInterface, now float64) {
+	a.times = append(a.times, req.(*blocks.Request).InitTime)
+}
+
+func (a *arrivals) SetName(name string) {}
+
+func TestTraceLoopArrivals(t *testing.T) {
+	rec := func(arrival float64) blocks.TraceRecord {
+		return blocks.TraceRecord{Arrival: arrival, Phases: []blocks.TracePhase{{0, []int{0}}}}
+	}
+	tests := []struct {
+		name    string
+		records []blocks.TraceRecord
+		scale   float64
+		want    []float64
+	}{
+		{"from 0", []blocks.TraceRecord{rec(0), rec(1), rec(2)}, 1, []float64{0, 1, 2, 3, 4, 5, 6}},
+		{"late start", []blocks.TraceRecord{rec(10), rec(11), rec(12)}, 1, []float64{10, 11, 12, 13, 14, 15, 16}},
+		{"late start scaled", []blocks.TraceRecord{rec(10), rec(11), rec(12)}, 2, []float64{20, 22, 24, 26, 28, 30, 32}},
+		{"uneven", []blocks.TraceRecord{rec(10), rec(12), rec(16)}, 1, []float64{10, 12, 16, 19, 21, 25, 28}},
+		{"one record", []blocks.TraceRecord{rec(5)}, 1, []float64{5, 10, 15, 20, 25, 30, 35}},
+		{"same time", []blocks.TraceRecord{rec(5), rec(5)}, 1, []float64{5, 5, 10, 10, 15, 15, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := engine.NewSimulation(1)
+			g := blocks.NewTraceGenerator(tt.records, traceCreator{}, &blocks.RoundRobinDispatch{})
+			if err := g.SetTimeScale(tt.scale); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.SetLoop(true); err != nil {
+				t.Fatal(err)
+			}
+			g.SetBudget(len(tt.want))
+			q := blocks.NewQueue()
+			g.AddOutQueue(q)
+			p := &blocks.RTCProcessor{}
+			p.AddInQueue(q)
+			got := &arrivals{}
+			p.SetReqDrain(got)
+			sim.RegisterActor(p)
+			sim.RegisterActor(g)
+			if err := sim.Run(1e9, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.times, tt.want) {
+				t.Errorf("arrivals %v, want %v", got.times, tt.want)
+			}
+		})
+	}
+}
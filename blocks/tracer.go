@@ -0,0 +1,59 @@
+package blocks
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// Tracer is an engine.Observer that writes every simulation event as a tab
+// separated line: time, event, actor id and event details
+type Tracer struct {
+	w io.Writer
+}
+
+// NewTracer returns a new *Tracer writing to w
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// ActorBlocked traces an actor blocking on queues
+func (t *Tracer) ActorBlocked(now float64, actor int, queues []engine.QueueInterface) {
+	fmt.Fprintf(t.w, "%v\tblock\t%v\t%v\n", now, actor, queues)
+}
+
+// ActorBlockedWrite traces an actor blocking on a full queue
+func (t *Tracer) ActorBlockedWrite(now float64, actor int, q engine.QueueInterface) {
+	fmt.Fprintf(t.w, "%v\tblockwrite\t%v\t%v\n", now, actor, q)
+}
+
+// TimerScheduled traces an actor waiting
+func (t *Tracer) TimerScheduled(now, at float64, actor int) {
+	fmt.Fprintf(t.w, "%v\twait\t%v\t%v\n", now, actor, at)
+}
+
+// ActorWoken traces an actor resuming
+func (t *Tracer) ActorWoken(now float64, actor int) {
+	fmt.Fprintf(t.w, "%v\twake\t%v\n", now, actor)
+}
+
+// Enqueued traces a request written to a queue
+func (t *Tracer) Enqueued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	fmt.Fprintf(t.w, "%v\tenqueue\t%v\t%v\t%v\n", now, actor, q, q.Len())
+}
+
+// Dequeued traces a request read from a queue
+func (t *Tracer) Dequeued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	fmt.Fprintf(t.w, "%v\tdequeue\t%v\t%v\t%v\n", now, actor, q, q.Len())
+}
+
+// Created traces a new request
+func (t *Tracer) Created(now float64, actor int, req engine.ReqInterface) {
+	fmt.Fprintf(t.w, "%v\tcreate\t%v\t%v\n", now, actor, req.GetServiceTime())
+}
+
+// Terminated traces a finished request
+func (t *Tracer) Terminated(now float64, actor int, req engine.ReqInterface) {
+	fmt.Fprintf(t.w, "%v\tterminate\t%v\t%v\n", now, actor, req.GetDelay(now))
+}
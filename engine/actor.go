@@ -7,10 +7,24 @@ import (
 // Actor is the basic simulation element. Every element (generator or processor)
 // should have an actor as a nested struct.
 type Actor struct {
+	sim       *Simulation
+	id        int
+	generator bool
+	monitor   bool
 	toModel   chan interface{}
 	wakeUpCh  chan int
+	yield     func(interface{}) bool
+	next      func() (interface{}, bool)
+	stop      func()
 	inQueues  []QueueInterface
 	outQueues []QueueInterface
+	wokenBy   QueueInterface
+	usage     Usage
+	// the event the actor is blocked with and since when
+	blockedOn    interface{}
+	blockedSince float64
+	streams      randStreams
+	rng          *rand.Rand
 }
 
 func (a *Actor) GetInQueues() []QueueInterface {
@@ -21,21 +35,120 @@ func (a *Actor) GetOutQueues() []QueueInterface {
 	return a.outQueues
 }
 
-func (a *Actor) init(ch chan interface{}) {
-	a.toModel = ch
-	a.wakeUpCh = make(chan int)
+func (a *Actor) getActor() *Actor {
+	return a
+}
+
+// block hands an event to the model and returns when the model wakes the
+// actor up
+func (a *Actor) block(e interface{}) {
+	if len(a.sim.observers) > 0 {
+		a.sim.observeBlock(a, e)
+	}
+	a.blockedOn, a.blockedSince = e, a.sim.time
+	a.sim.exec.block(a, e)
+	a.account(e, a.blockedSince)
+	a.blockedOn = nil
+	for _, o := range a.sim.observers {
+		o.ActorWoken(a.sim.time, a.id)
+	}
+}
+
+func (a *Actor) enqueue(q QueueInterface, el ReqInterface) {
+	q.Enqueue(el)
+	a.sim.enqueued(q)
+	for _, o := range a.sim.observers {
+		o.Enqueued(a.sim.time, a.id, q, el)
+	}
+}
+
+func (a *Actor) dequeue(q QueueInterface) ReqInterface {
+	el := q.Dequeue()
+	a.sim.dequeued(q)
+	for _, o := range a.sim.observers {
+		o.Dequeued(a.sim.time, a.id, q, el)
+	}
+	return el
+}
+
+// ReportCreation lets the simulation know that the actor created a new
+// request. Generators call it for every request they inject, so that the
+// simulation can account for the requests in flight
+func (a *Actor) ReportCreation(req ReqInterface) {
+	a.sim.created++
+	for _, o := range a.sim.observers {
+		o.Created(a.sim.time, a.id, req)
+	}
+}
+
+// ReportTermination lets the simulation observers know that the actor
+// finished a request. Processors call it before handing the request to
+// their RequestDrain
+func (a *Actor) ReportTermination(req ReqInterface) {
+	a.sim.terminated++
+	for _, o := range a.sim.observers {
+		o.Terminated(a.sim.time, a.id, req)
+	}
+}
+
+func (a *Actor) init(s *Simulation, id int, seed int64) {
+	a.sim = s
+	a.id = id
+	a.generator = false
+	a.monitor = false
+	a.streams = randStreams{seed: seed}
+	a.rng = a.NewRand()
+	for _, q := range a.outQueues {
+		s.registerQueue(q)
+	}
+	for _, q := range a.inQueues {
+		s.registerReader(q)
+	}
+}
+
+// GetSim returns the simulation the actor is registered with
+func (a *Actor) GetSim() *Simulation {
+	return a.sim
+}
+
+// GetID returns the actor id, which is its registration index
+func (a *Actor) GetID() int {
+	return a.id
+}
+
+// GetTime returns the current time of the actor's simulation
+func (a *Actor) GetTime() float64 {
+	return a.sim.GetTime()
+}
+
+// NewRand returns a new random stream derived from the actor seed.
+// Streams are derived in call order, so an actor asking for them in the same
+// order gets the same numbers for the same simulation seed.
+// It should only be called after the actor is registered
+func (a *Actor) NewRand() *rand.Rand {
+	return a.streams.newRand()
+}
+
+// Rand returns the actor's own random stream, used for its random decisions
+func (a *Actor) Rand() *rand.Rand {
+	return a.rng
 }
 
 // AddInQueue adds another input queue.
 // Input queues should be added in decreasing priority
 func (a *Actor) AddInQueue(q QueueInterface) {
+	if a.sim != nil {
+		a.sim.registerReader(q)
+	}
 	a.inQueues = append(a.inQueues, q)
 }
 
 // AddOutQueue adds another output queue.
 // Output queues should be added in decreasing priority
 func (a *Actor) AddOutQueue(q QueueInterface) {
-	mdl.queues[q] = true
+	if a.sim != nil {
+		a.sim.registerQueue(q)
+	}
 	a.outQueues = append(a.outQueues, q)
 }
 
@@ -77,65 +190,128 @@ func (a *Actor) GetInQueueCount() int {
 	return len(a.inQueues)
 }
 
-// Wait blocks the actor for a specific duration d
+// Wait blocks the actor for a specific duration d, accounted as service
+// time
 func (a *Actor) Wait(d float64) {
-	e := timerEvent{time: d + mdl.getTime(), wakeUpCh: a.wakeUpCh}
-	a.toModel <- e
-	<-a.wakeUpCh // block
+	a.WaitFor(d, WaitService)
+}
+
+// WaitFor blocks the actor for a d interval, accounting it to the given
+// reason in the actor usage
+func (a *Actor) WaitFor(d float64, reason WaitReason) {
+	e := timerEvent{time: d + a.sim.GetTime(), actor: a, reason: reason}
+	a.block(e)
 }
 
 // WaitInterruptible blocks the actor for a d interval, unless there is an
 // incoming request in the first input queue.
 // Returns true, nil if woken up by the timeout or false, ReqInterface
-// if woken up by the incoming req. If red is negative just read input queue
+// if woken up by the incoming req. If red is negative just read input queue.
+// The time waiting is idle
 func (a *Actor) WaitInterruptible(d float64) (bool, ReqInterface) {
+	return a.WaitInterruptibleFor(d, waitQueue)
+}
+
+// WaitInterruptibleFor is WaitInterruptible, accounting the time waiting
+// to the given reason in the actor usage
+func (a *Actor) WaitInterruptibleFor(d float64, reason WaitReason) (bool, ReqInterface) {
 	if a.inQueues[0].Len() > 0 {
-		return false, a.inQueues[0].Dequeue()
+		return false, a.dequeue(a.inQueues[0])
 	}
 
 	// Negative timeout - no timeout
 	if d < 0 {
 		return false, a.ReadInQueue()
 	}
-	timeoutTime := d + mdl.getTime()
+	timeoutTime := d + a.sim.GetTime()
 	lEvent := linkedEvent{
-		timerEvent: timerEvent{time: timeoutTime, wakeUpCh: a.wakeUpCh},
-		blockEvent: blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues},
+		timerEvent: timerEvent{time: timeoutTime, actor: a, reason: reason},
+		blockEvent: blockEvent{actor: a, queues: a.inQueues},
 	}
-	a.toModel <- lEvent
-	<-a.wakeUpCh
+	a.block(lEvent)
 
 	if a.inQueues[0].Len() > 0 {
-		return false, a.inQueues[0].Dequeue()
+		return false, a.dequeue(a.inQueues[0])
 	}
-	if mdl.getTime() == timeoutTime {
+	if a.sim.GetTime() == timeoutTime {
 		return true, nil
 	}
 
 	return false, nil
 }
 
+// WaitInterruptibleAny blocks the actor for a d interval, unless there is an
+// incoming request in any of the input queues. It is
+// WaitInterruptibleQueues on all the input queues
+func (a *Actor) WaitInterruptibleAny(d float64) (int, ReqInterface) {
+	idxs := make([]int, len(a.inQueues))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	return a.WaitInterruptibleQueues(d, idxs...)
+}
+
+// WaitInterruptibleQueues blocks the actor for a d interval, unless there is
+// an incoming request in one of the input queues with the given indices.
+// Returns the index of the input queue and the request read from it, or
+// -1, nil if woken up by the timeout. If requests are already waiting, the
+// first non empty queue in the given order is read. If d is negative there
+// is no timeout. The time waiting is idle
+func (a *Actor) WaitInterruptibleQueues(d float64, idxs ...int) (int, ReqInterface) {
+	timeoutTime := d + a.sim.GetTime()
+	queues := make([]QueueInterface, len(idxs))
+	for i, idx := range idxs {
+		queues[i] = a.inQueues[idx]
+	}
+
+	for {
+		for i, q := range queues {
+			if q.Len() > 0 {
+				return idxs[i], a.dequeue(q)
+			}
+		}
+
+		a.wokenBy = nil
+		if d < 0 {
+			a.block(blockEvent{actor: a, queues: queues})
+		} else {
+			a.block(linkedEvent{
+				timerEvent: timerEvent{time: timeoutTime, actor: a, reason: waitQueue},
+				blockEvent: blockEvent{actor: a, queues: queues},
+			})
+		}
+		if a.wokenBy == nil {
+			return -1, nil
+		}
+
+		// prefer the queue that woke the actor up
+		for i, q := range queues {
+			if q == a.wokenBy && q.Len() > 0 {
+				return idxs[i], a.dequeue(q)
+			}
+		}
+	}
+}
+
 // ReadInQueue tries to read the first input queue. If there is a ReqInterface
 // available it returns, otherwise the actor blocks
 func (a *Actor) ReadInQueue() ReqInterface {
 	if a.inQueues[0].Len() > 0 {
-		return a.inQueues[0].Dequeue()
+		return a.dequeue(a.inQueues[0])
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueue()
 }
 
 func (a *Actor) ReadInQueueI(idx int) ReqInterface {
 	if a.inQueues[idx].Len() > 0 {
-		return a.inQueues[idx].Dequeue()
+		return a.dequeue(a.inQueues[idx])
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueueI(idx)
 }
 
@@ -146,13 +322,12 @@ func (a *Actor) ReadInQueueI(idx int) ReqInterface {
 func (a *Actor) ReadInQueues() (ReqInterface, int) {
 	for i, q := range a.inQueues {
 		if q.Len() > 0 {
-			return q.Dequeue(), i
+			return a.dequeue(q), i
 		}
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 
 	return a.ReadInQueues()
 }
@@ -172,13 +347,12 @@ func (a *Actor) ReadInQueuesRand() (ReqInterface, int) {
 		}
 	}
 	if len(available) > 0 {
-		q := available[rand.Intn(len(available))]
-		return q.q.Dequeue(), q.idx
+		q := available[a.rng.Intn(len(available))]
+		return a.dequeue(q.q), q.idx
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueues()
 }
 
@@ -187,7 +361,7 @@ func (a *Actor) ReadInQueuesRand() (ReqInterface, int) {
 // Returns the ReqInterface and from which queue it was read
 func (a *Actor) ReadInQueuesRandLocalPr() (ReqInterface, int) {
 	if a.inQueues[0].Len() > 0 {
-		return a.inQueues[0].Dequeue(), 0
+		return a.dequeue(a.inQueues[0]), 0
 	}
 	var available []queueIdx
 	for i, q := range a.inQueues {
@@ -196,33 +370,45 @@ func (a *Actor) ReadInQueuesRandLocalPr() (ReqInterface, int) {
 		}
 	}
 	if len(available) > 0 {
-		q := available[rand.Intn(len(available))]
-		return q.q.Dequeue(), q.idx
+		q := available[a.rng.Intn(len(available))]
+		return a.dequeue(q.q), q.idx
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueuesRandLocalPr()
 }
 
 // WriteOutQueue writes a ReqInterface to the first output queue
 func (a *Actor) WriteOutQueue(el ReqInterface) {
-	a.outQueues[0].Enqueue(el)
+	a.enqueue(a.outQueues[0], el)
 }
 
 // WriteInQueue writes a ReqInterface to the first input queue
 // It can be used for feedback loops
 func (a *Actor) WriteInQueue(el ReqInterface) {
-	a.inQueues[0].Enqueue(el)
+	a.enqueue(a.inQueues[0], el)
 }
 
 // WriteOutQueueI writes a ReqInterface to the given i out queue
 func (a *Actor) WriteOutQueueI(el ReqInterface, i int) {
-	a.outQueues[i].Enqueue(el)
+	a.enqueue(a.outQueues[i], el)
+}
+
+// WriteOutQueueIBlocking writes a ReqInterface to the given i out queue.
+// If the queue is a full BoundedQueueInterface the actor blocks until
+// there is space
+func (a *Actor) WriteOutQueueIBlocking(el ReqInterface, i int) {
+	q := a.outQueues[i]
+	if bq, ok := q.(BoundedQueueInterface); ok {
+		for bq.Len() >= bq.Cap() {
+			a.block(spaceEvent{actor: a, queue: bq})
+		}
+	}
+	a.enqueue(q, el)
 }
 
 // WriteInQueueI writes a ReqInterface to the given i in queue
 func (a *Actor) WriteInQueueI(el ReqInterface, i int) {
-	a.inQueues[i].Enqueue(el)
+	a.enqueue(a.inQueues[i], el)
 }
@@ -0,0 +1,141 @@
+package engine_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// burst is a generator that writes n requests of service time 2 at time 0,
+// blocking while its out queue is full
+type burst struct {
+	engine.Actor
+	n int
+}
+
+func (g *burst) Run() {
+	for i := 0; i < g.n; i++ {
+		req := &blocks.Request{ServiceTime: 2}
+		g.ReportCreation(req)
+		g.WriteOutQueueIBlocking(req, 0)
+	}
+}
+
+func (g *burst) IsGenerator() bool {
+	return true
+}
+
+// enqueueTimes records the time of every enqueue
+type enqueueTimes struct {
+	engine.NopObserver
+	times []float64
+}
+
+func (o *enqueueTimes) Enqueued(now float64, actor int, q engine.QueueInterface, req engine.ReqInterface) {
+	o.times = append(o.times, now)
+}
+
+func TestWriteBlocking(t *testing.T) {
+	tests := []struct {
+		capacity int
+		times    []float64
+		blocked  float64
+	}{
+		{1, []float64{0, 0, 2, 4}, 4},
+		{2, []float64{0, 0, 0, 2}, 2},
+		{4, []float64{0, 0, 0, 0}, 0},
+	}
+	for _, tt := range tests {
+		for _, b := range backends {
+			sim := engine.NewSimulation(1)
+			sim.SetBackend(b.backend)
+			obs := &enqueueTimes{}
+			sim.AddObserver(obs)
+			q := blocks.NewBoundedQueue(tt.capacity)
+			g := &burst{n: 4}
+			g.AddOutQueue(q)
+			p := &blocks.RTCProcessor{}
+			p.AddInQueue(q)
+			p.SetReqDrain(&delaySum{})
+			sim.RegisterActor(g)
+			sim.RegisterActor(p)
+			if err := sim.Run(100, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			// the writer is woken as soon as the core reads a request
+			if !reflect.DeepEqual(obs.times, tt.times) {
+				t.Errorf("capacity %v, %v backend: writes at %v, want %v", tt.capacity, b.name, obs.times, tt.times)
+			}
+			if got := sim.GetUsage(g.GetID()).Blocked; got != tt.blocked {
+				t.Errorf("capacity %v, %v backend: blocked for %v, want %v", tt.capacity, b.name, got, tt.blocked)
+			}
+			if sim.GetTime() != 8 {
+				t.Errorf("capacity %v, %v backend: ended at %v, want 8", tt.capacity, b.name, sim.GetTime())
+			}
+		}
+	}
+}
+
+// queueWaiter waits for a request on some of its three input queues and
+// then serves for 20
+type queueWaiter struct {
+	engine.Actor
+	timeout float64
+	idxs    []int
+	idx     int
+	req     engine.ReqInterface
+	woken   float64
+	served  float64
+}
+
+func (w *queueWaiter) Run() {
+	w.idx, w.req = w.WaitInterruptibleQueues(w.timeout, w.idxs...)
+	w.woken = w.GetTime()
+	w.Wait(20)
+	w.served = w.GetTime() - w.woken
+}
+
+func TestWaitInterruptibleQueues(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout float64
+		idxs    []int
+		write   int // the input queue written at 3, or -1
+		idx     int
+		woken   float64
+	}{
+		{"second queue", 10, []int{1, 2}, 1, 1, 3},
+		{"third queue", 10, []int{1, 2}, 2, 2, 3},
+		{"queue not waited on", 10, []int{1, 2}, 0, -1, 10},
+		{"no write", 10, []int{1, 2}, -1, -1, 10},
+		{"no timeout", -1, []int{0, 2}, 2, 2, 3},
+	}
+	for _, tt := range tests {
+		for _, b := range backends {
+			sim := engine.NewSimulation(1)
+			sim.SetBackend(b.backend)
+			w := &queueWaiter{timeout: tt.timeout, idxs: tt.idxs}
+			for i := 0; i < 3; i++ {
+				w.AddInQueue(blocks.NewQueue())
+			}
+			sim.RegisterActor(w)
+			if tt.write >= 0 {
+				s := &sender{delay: 3}
+				s.AddOutQueue(w.GetInQueues()[tt.write])
+				sim.RegisterActor(s)
+			}
+			// the run ends with no generator, which is reported as a deadlock
+			sim.Run(100, 0, 0)
+			if w.idx != tt.idx || (w.req != nil) != (tt.idx >= 0) || w.woken != tt.woken {
+				t.Errorf("%v, %v backend: got queue %v request %v at %v, want queue %v at %v",
+					tt.name, b.name, w.idx, w.req, w.woken, tt.idx, tt.woken)
+			}
+			// a timeout left behind would cut the service short
+			if w.served != 20 {
+				t.Errorf("%v, %v backend: served for %v, want 20", tt.name, b.name, w.served)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueueState describes a queue and its length when a diagnostic was taken.
+// Queues are named by their String method if they have one, or by their
+// registration index otherwise
+type QueueState struct {
+	Name string
+	Len  int
+}
+
+// BlockedActor describes an actor blocked on its input queues, or waiting
+// for space in a full bounded queue if Write is set
+type BlockedActor struct {
+	Name   string
+	Write  bool
+	Queues []QueueState
+}
+
+// Diagnostic is returned by Run when the simulation could not go on, or when
+// the orphan check found queues that no actor reads
+type Diagnostic struct {
+	Time     float64
+	Deadlock bool
+	Blocked  []BlockedActor
+	Orphans  []QueueState
+}
+
+func (d *Diagnostic) Error() string {
+	var b strings.Builder
+	if d.Deadlock {
+		fmt.Fprintf(&b, "deadlock at time %v: every actor is blocked and no event is pending\n", d.Time)
+	} else {
+		fmt.Fprintf(&b, "simulation ended at time %v with orphan queues\n", d.Time)
+	}
+	for _, a := range d.Blocked {
+		if a.Write {
+			fmt.Fprintf(&b, "\t%v blocked writing to:", a.Name)
+		} else {
+			fmt.Fprintf(&b, "\t%v blocked on:", a.Name)
+		}
+		for _, q := range a.Queues {
+			fmt.Fprintf(&b, " %v (len %v)", q.Name, q.Len)
+		}
+		b.WriteString("\n")
+	}
+	for _, q := range d.Orphans {
+		fmt.Fprintf(&b, "\t%v has %v requests and no reader\n", q.Name, q.Len)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (s *Simulation) queueState(q QueueInterface) QueueState {
+	if str, ok := q.(fmt.Stringer); ok {
+		return QueueState{Name: str.String(), Len: q.Len()}
+	}
+	idx := 0
+	for i, bq := range s.queues {
+		if bq.q == q {
+			idx = i
+			break
+		}
+	}
+	return QueueState{Name: fmt.Sprintf("queue #%v", idx), Len: q.Len()}
+}
+
+func actorName(a ActorInterface) string {
+	return fmt.Sprintf("actor %v (%T)", a.getActor().id, a)
+}
+
+// deadlock returns the diagnostic of a simulation with every actor blocked
+func (s *Simulation) deadlock() *Diagnostic {
+	d := &Diagnostic{Time: s.time, Deadlock: true}
+
+	blocked := make(map[*Actor]BlockedActor)
+	for _, bq := range s.queues {
+		for e := bq.waiting.Front(); e != nil; e = e.Next() {
+			be := e.Value.(blockEventInterface)
+			ba := BlockedActor{}
+			for _, q := range be.getQueues() {
+				ba.Queues = append(ba.Queues, s.queueState(q))
+			}
+			blocked[be.getActor()] = ba
+		}
+		for e := bq.writers.Front(); e != nil; e = e.Next() {
+			blocked[e.Value.(*Actor)] = BlockedActor{Write: true, Queues: []QueueState{s.queueState(bq.q)}}
+		}
+	}
+	for _, a := range s.actors {
+		ba, ok := blocked[a.getActor()]
+		if !ok {
+			continue
+		}
+		ba.Name = actorName(a)
+		d.Blocked = append(d.Blocked, ba)
+	}
+	if s.orphanCheck {
+		d.Orphans = s.orphanQueues()
+	}
+	return d
+}
+
+// orphanQueues returns the queues that hold requests but have no reader
+func (s *Simulation) orphanQueues() []QueueState {
+	var res []QueueState
+	for _, bq := range s.queues {
+		if bq.readers == 0 && bq.q.Len() > 0 {
+			res = append(res, s.queueState(bq.q))
+		}
+	}
+	return res
+}
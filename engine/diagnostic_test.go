@@ -0,0 +1,139 @@
+package engine_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// relay forwards the requests of its input queue to its output queue. If
+// writes is positive it first writes that many requests to the output
+// queue, blocking while it is full
+type relay struct {
+	engine.Actor
+	writes int
+}
+
+func (r *relay) Run() {
+	for i := 0; i < r.writes; i++ {
+		r.WriteOutQueueIBlocking(&blocks.Request{}, 0)
+	}
+	for {
+		r.WriteOutQueue(r.ReadInQueue())
+	}
+}
+
+func newQueue(label string) *blocks.Queue {
+	q := blocks.NewQueue()
+	q.SetLabel(label)
+	return q
+}
+
+func TestDeadlockDiagnostic(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(sim *engine.Simulation)
+		want  *engine.Diagnostic
+	}{
+		{
+			name: "reader of a queue nobody writes",
+			build: func(sim *engine.Simulation) {
+				p := &blocks.RTCProcessor{}
+				p.AddInQueue(newQueue("in"))
+				p.SetReqDrain(&delaySum{})
+				sim.RegisterActor(p)
+			},
+			want: &engine.Diagnostic{Deadlock: true, Blocked: []engine.BlockedActor{
+				{Name: "actor 0 (*blocks.RTCProcessor)", Queues: []engine.QueueState{{Name: "in"}}},
+			}},
+		},
+		{
+			name: "cycle of readers",
+			build: func(sim *engine.Simulation) {
+				a, b := newQueue("a"), newQueue("b")
+				r1, r2 := &relay{}, &relay{}
+				r1.AddInQueue(a)
+				r1.AddOutQueue(b)
+				r2.AddInQueue(b)
+				r2.AddOutQueue(a)
+				sim.RegisterActor(r1)
+				sim.RegisterActor(r2)
+			},
+			want: &engine.Diagnostic{Deadlock: true, Blocked: []engine.BlockedActor{
+				{Name: "actor 0 (*engine_test.relay)", Queues: []engine.QueueState{{Name: "a"}}},
+				{Name: "actor 1 (*engine_test.relay)", Queues: []engine.QueueState{{Name: "b"}}},
+			}},
+		},
+		{
+			name: "writer of a full queue nobody reads",
+			build: func(sim *engine.Simulation) {
+				full := blocks.NewBoundedQueue(1)
+				full.SetLabel("full")
+				r := &relay{writes: 2}
+				r.AddInQueue(newQueue("in"))
+				r.AddOutQueue(full)
+				sim.RegisterActor(r)
+				sim.SetOrphanCheck(true)
+			},
+			want: &engine.Diagnostic{Deadlock: true, Blocked: []engine.BlockedActor{
+				{Name: "actor 0 (*engine_test.relay)", Write: true, Queues: []engine.QueueState{{Name: "full", Len: 1}}},
+			}, Orphans: []engine.QueueState{{Name: "full", Len: 1}}},
+		},
+		{
+			name: "reader of a queue nobody writes with a sampler",
+			build: func(sim *engine.Simulation) {
+				p := &blocks.RTCProcessor{}
+				p.AddInQueue(newQueue("in"))
+				stats := &blocks.AllKeeper{}
+				p.SetReqDrain(stats)
+				sim.RegisterActor(p)
+				sim.RegisterActor(blocks.NewSampler(1, stats, io.Discard))
+			},
+			want: &engine.Diagnostic{Deadlock: true, Blocked: []engine.BlockedActor{
+				{Name: "actor 0 (*blocks.RTCProcessor)", Queues: []engine.QueueState{{Name: "in"}}},
+			}},
+		},
+		{
+			name: "no deadlock",
+			build: func(sim *engine.Simulation) {
+				q := newQueue("in")
+				g := blocks.NewMMRandGenerator(0.5, 1)
+				g.SetCreator(&blocks.SimpleReqCreator{})
+				g.SetBudget(10)
+				g.AddOutQueue(q)
+				p := &blocks.RTCProcessor{}
+				p.AddInQueue(q)
+				p.SetReqDrain(&delaySum{})
+				sim.RegisterActor(p)
+				sim.RegisterActor(g)
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, b := range backends {
+			t.Run(tt.name+"/"+b.name, func(t *testing.T) {
+				sim := engine.NewSimulation(1)
+				sim.SetBackend(b.backend)
+				tt.build(sim)
+				err := sim.Run(100, 0, 0)
+				if tt.want == nil {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				var d *engine.Diagnostic
+				if !errors.As(err, &d) {
+					t.Fatalf("got %v, want a diagnostic", err)
+				}
+				if !reflect.DeepEqual(d, tt.want) {
+					t.Errorf("got %+v, want %+v", d, tt.want)
+				}
+			})
+		}
+	}
+}
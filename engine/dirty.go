@@ -0,0 +1,91 @@
+package engine
+
+import "container/heap"
+
+// queueHeap is a min-heap of queue registration indices
+type queueHeap []int
+
+func (h queueHeap) Len() int           { return len(h) }
+func (h queueHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h queueHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *queueHeap) Push(x interface{}) {
+	*h = append(*h, x.(int))
+}
+
+func (h *queueHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// dirtyQueues keeps the queues that might wake up blocked actors: queues
+// that got requests while actors wait to read them, queues that got space
+// while actors wait to write them and queues actors just blocked on. Only
+// those are checked after every event.
+// Dirty queues are checked in passes in registration order. A queue dirtied
+// during a pass is checked in the same pass if it comes after the queue
+// being checked, otherwise in the next one. This wakes actors in the same
+// order as checking every queue in passes till a pass wakes none
+type dirtyQueues struct {
+	// pos is the index after the queue being checked in the current pass
+	pos  int
+	cur  queueHeap
+	next queueHeap
+}
+
+// mark adds a queue to the dirty queues
+func (d *dirtyQueues) mark(bq *blockedQueue) {
+	if bq.dirty {
+		return
+	}
+	bq.dirty = true
+	if bq.idx >= d.pos {
+		heap.Push(&d.cur, bq.idx)
+	} else {
+		heap.Push(&d.next, bq.idx)
+	}
+}
+
+// pop returns the next dirty queue to check, or nil if there is none
+func (d *dirtyQueues) pop(queues []*blockedQueue) *blockedQueue {
+	if d.cur.Len() == 0 {
+		d.cur, d.next = d.next, d.cur
+		d.pos = 0
+		if d.cur.Len() == 0 {
+			return nil
+		}
+	}
+	bq := queues[heap.Pop(&d.cur).(int)]
+	bq.dirty = false
+	d.pos = bq.idx + 1
+	return bq
+}
+
+// wakeable returns whether the queue has requests for blocked readers or
+// space for blocked writers
+func (bq *blockedQueue) wakeable() bool {
+	if bq.waiting.Len() > 0 && bq.q.Len() > 0 {
+		return true
+	}
+	if bq.writers.Len() > 0 {
+		q := bq.q.(BoundedQueueInterface)
+		return q.Len() < q.Cap()
+	}
+	return false
+}
+
+// enqueued marks q dirty if actors wait to read it
+func (s *Simulation) enqueued(q QueueInterface) {
+	if bq, ok := s.blockedInQueues[q]; ok && bq.waiting.Len() > 0 {
+		s.dirty.mark(bq)
+	}
+}
+
+// dequeued marks q dirty if actors wait to write it
+func (s *Simulation) dequeued(q QueueInterface) {
+	if bq, ok := s.blockedInQueues[q]; ok && bq.writers.Len() > 0 {
+		s.dirty.mark(bq)
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// SetDrain enables the drain phase. When the simulation reaches its
+// threshold time the generators are stopped and the rest of the actors run
+// till every request in flight is finished
+func (s *Simulation) SetDrain(drain bool) {
+	s.drain = drain
+}
+
+// InFlight returns the number of requests that were created but not
+// finished when the simulation reached its threshold time, or when it ended
+// before that
+func (s *Simulation) InFlight() int {
+	return s.inFlight
+}
+
+// fromGenerator returns whether a generator blocked with the event
+func (s *Simulation) fromGenerator(newEvent interface{}) bool {
+	switch e := newEvent.(type) {
+	case timerEvent:
+		return e.actor.generator
+	case blockEvent:
+		return e.actor.generator
+	case linkedEvent:
+		return e.blockEvent.actor.generator
+	}
+	return false
+}
+
+// stopGenerators removes the pending events of the generators, so that they
+// are never woken up again. Generators blocked on a full queue still write
+// the request they created, but block no more after that
+func (s *Simulation) stopGenerators() {
+	s.draining = true
+	pq := s.pq[:0]
+	for _, e := range s.pq {
+		if e.getActor().generator {
+			s.timerRemoved(e)
+			if linkedE, ok := e.(*linkedEvent); ok {
+				linkedE.blockEvent.deactivateReplicas()
+			}
+			continue
+		}
+		// the kept events move, heap.Remove needs their new index
+		e.setIdx(len(pq))
+		pq = append(pq, e)
+	}
+	s.pq = pq
+	heap.Init(&s.pq)
+
+	for _, bq := range s.queues {
+		for e := bq.waiting.Front(); e != nil; {
+			next := e.Next()
+			be := e.Value.(blockEventInterface)
+			if be.getActor().generator {
+				be.deactivateReplicas()
+			}
+			e = next
+		}
+	}
+}
+
+// actorDone is called by the executors when the Run of an actor returns
+func (s *Simulation) actorDone(a *Actor) {
+	if a.generator && !a.monitor {
+		s.exhausted++
+	}
+}
+
+// exhaustedAll returns whether every generator is exhausted and every
+// request it created is finished
+func (s *Simulation) exhaustedAll() bool {
+	return s.sources > 0 && s.exhausted == s.sources && s.created == s.terminated
+}
+
+// printInFlight prints the requests in flight when the run ended, labeled
+// with what ended it, if draining. The time the drain ended is printed
+// after a cutoff
+func (s *Simulation) printInFlight() {
+	if !s.drain {
+		return
+	}
+	if s.stopReason != "cutoff" {
+		fmt.Printf("In-flight at %v: %v\n", s.stopReason, s.inFlight)
+		return
+	}
+	fmt.Printf("In-flight at cutoff: %v\tDrained till: %v\n", s.inFlight, s.time)
+}
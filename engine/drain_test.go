@@ -0,0 +1,86 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// ticker is a generator that only keeps a timer pending
+type ticker struct {
+	engine.Actor
+}
+
+func (g *ticker) Run() {
+	for {
+		g.Wait(1)
+	}
+}
+
+func (g *ticker) IsGenerator() bool {
+	return true
+}
+
+// sender writes a request to its out queue after a delay
+type sender struct {
+	engine.Actor
+	delay float64
+}
+
+func (s *sender) Run() {
+	s.Wait(s.delay)
+	s.WriteOutQueue(&blocks.Request{})
+}
+
+// listener waits for a request with a timeout and records when it stopped
+// waiting and whether it got a request
+type listener struct {
+	engine.Actor
+	timeout float64
+	woken   float64
+	got     bool
+}
+
+func (l *listener) Run() {
+	timedOut, req := l.WaitInterruptible(l.timeout)
+	l.woken, l.got = l.GetTime(), !timedOut && req != nil
+}
+
+func TestDrainInterruptibleWait(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			sim := engine.NewSimulation(1)
+			sim.SetBackend(b.backend)
+			sim.SetDrain(true)
+			sim.RegisterActor(&ticker{})
+			// the listeners are still waiting when the generator stops at
+			// 10. The last one gets a request at 15, the rest time out
+			var listeners []*listener
+			for i := 0; i < 6; i++ {
+				l := &listener{timeout: float64(20 + i)}
+				l.AddInQueue(blocks.NewQueue())
+				sim.RegisterActor(l)
+				listeners = append(listeners, l)
+			}
+			s := &sender{delay: 15}
+			s.AddOutQueue(listeners[len(listeners)-1].GetInQueues()[0])
+			sim.RegisterActor(s)
+			if err := sim.Run(10, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			for i, l := range listeners {
+				woken, got := l.timeout, false
+				if i == len(listeners)-1 {
+					woken, got = 15, true
+				}
+				if l.woken != woken || l.got != got {
+					t.Errorf("listener %v woken at %v with request %v, want %v and %v", i, l.woken, l.got, woken, got)
+				}
+			}
+			if sim.GetTime() != 24 {
+				t.Errorf("drained till %v, want 24", sim.GetTime())
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"iter"
+	"sync"
+)
+
+// Backend selects how the actors of a simulation are executed
+type Backend int
+
+const (
+	// GoroutineBackend runs every actor in its own goroutine and hands
+	// control over channels
+	GoroutineBackend Backend = iota
+	// EventLoopBackend runs every actor as a coroutine resumed by the model
+	// event loop. Only one thread ever runs and no channels are used
+	EventLoopBackend
+)
+
+// executor starts and resumes actors on behalf of the model.
+// start and resume return the next event the actor blocked with, or nil if
+// the actor returned from Run and is done
+type executor interface {
+	start(a ActorInterface) interface{}
+	resume(a *Actor) interface{}
+	block(a *Actor, e interface{})
+	stop(actors []ActorInterface)
+}
+
+func newExecutor(b Backend) executor {
+	if b == EventLoopBackend {
+		return &loopExecutor{}
+	}
+	return newGoroutineExecutor()
+}
+
+// goroutineExecutor is the channel handoff backend
+type goroutineExecutor struct {
+	eventChan chan interface{}
+	running   sync.WaitGroup
+}
+
+func newGoroutineExecutor() *goroutineExecutor {
+	return &goroutineExecutor{eventChan: make(chan interface{})}
+}
+
+func (ge *goroutineExecutor) start(a ActorInterface) interface{} {
+	act := a.getActor()
+	act.toModel = ge.eventChan
+	act.wakeUpCh = make(chan int)
+	ge.running.Add(1)
+	go func() {
+		defer ge.running.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(actorStopped); !ok {
+					panic(r)
+				}
+			}
+		}()
+		a.Run()
+		// the actor is done and blocks with no event
+		act.sim.actorDone(act)
+		act.toModel <- nil
+	}()
+	return <-ge.eventChan
+}
+
+func (ge *goroutineExecutor) resume(a *Actor) interface{} {
+	a.wakeUpCh <- 1
+	return <-ge.eventChan
+}
+
+func (ge *goroutineExecutor) block(a *Actor, e interface{}) {
+	a.toModel <- e
+	if _, ok := <-a.wakeUpCh; !ok { // block
+		panic(actorStopped{})
+	}
+}
+
+// stop unwinds the actor goroutines still blocked and waits till they are
+// gone
+func (ge *goroutineExecutor) stop(actors []ActorInterface) {
+	for _, a := range actors {
+		close(a.getActor().wakeUpCh)
+	}
+	ge.running.Wait()
+}
+
+// actorStopped unwinds the stack of an actor when the simulation is over
+type actorStopped struct{}
+
+// loopExecutor is the single-threaded backend. Every Wait or blocking read
+// yields the event to the loop, which resumes the actor as a continuation
+type loopExecutor struct{}
+
+func (le *loopExecutor) start(a ActorInterface) interface{} {
+	act := a.getActor()
+	act.next, act.stop = iter.Pull(func(yield func(interface{}) bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(actorStopped); !ok {
+					panic(r)
+				}
+			}
+		}()
+		act.yield = yield
+		a.Run()
+		act.sim.actorDone(act)
+	})
+	return le.resume(act)
+}
+
+func (le *loopExecutor) resume(a *Actor) interface{} {
+	e, _ := a.next()
+	return e
+}
+
+func (le *loopExecutor) block(a *Actor, e interface{}) {
+	if !a.yield(e) {
+		panic(actorStopped{})
+	}
+}
+
+func (le *loopExecutor) stop(actors []ActorInterface) {
+	for _, a := range actors {
+		a.getActor().stop()
+	}
+}
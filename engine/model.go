@@ -3,67 +3,132 @@ package engine
 import (
 	"container/heap"
 	"container/list"
+	"math"
+	"math/rand"
 )
 
-var mdl *model
-
 // ActorInterface is the main interface to be used in main package.
 // Every element of the topology should implement this interface.
 // Init, AddInQueuem AddOutQueue are provided by the Actor nested struct and
-// only the Run() function needs to be implemented
+// only the Run() function needs to be implemented. An actor whose Run
+// returns is done and is never woken up again
 type ActorInterface interface {
 	Run()
 	AddInQueue(q QueueInterface)
 	AddOutQueue(q QueueInterface)
-	init(ch chan interface{})
+	init(s *Simulation, id int, seed int64)
+	getActor() *Actor
+}
+
+// GeneratorInterface is implemented by the actors that inject requests in
+// the simulation. When the simulation drains, generators are stopped at the
+// threshold time. A generator whose Run returns is exhausted; once every
+// generator is exhausted and every request is finished the simulation ends
+type GeneratorInterface interface {
+	ActorInterface
+	IsGenerator() bool
+}
+
+// MonitorInterface is implemented by the generators that only observe the
+// simulation and never create requests. The simulation does not wait for
+// them to be exhausted
+type MonitorInterface interface {
+	GeneratorInterface
+	IsMonitor() bool
 }
 
 // ReqInterface describes what a basic request should look like
 type ReqInterface interface {
-	GetDelay() float64
+	GetDelay(now float64) float64
 	GetServiceTime() float64
 	SubServiceTime(t float64)
 }
 
-// QueueInterface describe basic queue functionality
+// QueueInterface describe basic queue functionality.
+// Requests should only be written to and read from queues through actors,
+// so that the simulation wakes up the actors blocked on them
 type QueueInterface interface {
 	Enqueue(ReqInterface)
 	Dequeue() ReqInterface
 	Len() int
 }
 
+// BoundedQueueInterface describes a queue with a limited capacity.
+// Actors writing with WriteOutQueueIBlocking block while it is full
+type BoundedQueueInterface interface {
+	QueueInterface
+	Cap() int
+}
+
 // Stats is an interface that is called at the end of the simulation and
-// prints the collected statistics
+// prints the collected statistics. now is the simulation time at the end.
+// SetWindow is called before the simulation starts with the measurement
+// window the statistics should account for
 type Stats interface {
-	PrintStats()
+	SetWindow(w Window)
+	PrintStats(now float64)
+}
+
+// StopCondition lets a simulation end before its threshold time.
+// Done is checked after every event
+type StopCondition interface {
+	Done() bool
+}
+
+// Window is the measurement window of a simulation
+type Window struct {
+	Start float64
+	End   float64
+}
+
+// Contains returns whether t falls in the window
+func (w Window) Contains(t float64) bool {
+	return t >= w.Start && t < w.End
+}
+
+// Length returns the window length, if the simulation stopped at now
+func (w Window) Length(now float64) float64 {
+	return math.Min(w.End, now) - w.Start
 }
 
 type timerEventInterface interface {
 	getTime() float64
+	getSeq() uint64
+	setSeq(seq uint64)
 	setIdx(idx int)
-	getChannel() chan int
+	getActor() *Actor
 }
 
 type timerEvent struct {
-	time     float64
-	wakeUpCh chan int
-	idx      int
+	time   float64
+	seq    uint64
+	actor  *Actor
+	idx    int
+	reason WaitReason
 }
 
 func (te *timerEvent) getTime() float64 {
 	return te.time
 }
 
+func (te *timerEvent) getSeq() uint64 {
+	return te.seq
+}
+
+func (te *timerEvent) setSeq(seq uint64) {
+	te.seq = seq
+}
+
 func (te *timerEvent) setIdx(idx int) {
 	te.idx = idx
 }
 
-func (te *timerEvent) getChannel() chan int {
-	return te.wakeUpCh
+func (te *timerEvent) getActor() *Actor {
+	return te.actor
 }
 
 type blockEventInterface interface {
-	getChannel() chan int
+	getActor() *Actor
 	getQueues() []QueueInterface
 	deactivateReplicas()
 	addReplica(pair listElPair)
@@ -75,13 +140,13 @@ type listElPair struct {
 }
 
 type blockEvent struct {
-	wakeUpCh chan int
+	actor    *Actor
 	queues   []QueueInterface
 	replicas []listElPair
 }
 
-func (be *blockEvent) getChannel() chan int {
-	return be.wakeUpCh
+func (be *blockEvent) getActor() *Actor {
+	return be.actor
 }
 
 func (be *blockEvent) getQueues() []QueueInterface {
@@ -98,152 +163,349 @@ func (be *blockEvent) addReplica(pair listElPair) {
 	be.replicas = append(be.replicas, pair)
 }
 
+// spaceEvent blocks an actor till a bounded queue has space
+type spaceEvent struct {
+	actor *Actor
+	queue BoundedQueueInterface
+}
+
 type linkedEvent struct {
 	timerEvent
 	blockEvent
 }
 
-func (le *linkedEvent) getChannel() chan int {
-	return le.blockEvent.wakeUpCh
+func (le *linkedEvent) getActor() *Actor {
+	return le.blockEvent.actor
 }
 
-type model struct {
+// Simulation is a single simulation instance. It owns its clock, event heap,
+// queues, actors and statistics, so many simulations can run concurrently
+// in different goroutines
+type Simulation struct {
 	time            float64
-	actorCount      int
+	actors          []ActorInterface
 	pq              priorityQueue
-	eventChan       chan interface{}
-	blockedInQueues map[QueueInterface]*list.List
-	queues          map[QueueInterface]bool
+	exec            executor
+	eventSeq        uint64
+	blockedInQueues map[QueueInterface]*blockedQueue
+	queues          []*blockedQueue
 	bookkeeping     []Stats
+	stopConditions  []StopCondition
+	observers       []Observer
+	orphanCheck     bool
+	usageReport     bool
+	drain           bool
+	draining        bool
+	created         int
+	terminated      int
+	inFlight        int
+	stopReason      string
+	dirty           dirtyQueues
+	monitorTimers   int
+	sources         int
+	exhausted       int
+	window          Window
+	streams         randStreams
+}
+
+// NewSimulation returns a new *Simulation running on the goroutine backend.
+// Every random stream of the simulation is derived from the given seed, so
+// the same seed and topology always give the same results
+func NewSimulation(seed int64) *Simulation {
+	s := &Simulation{}
+	s.streams = randStreams{seed: seed}
+	s.exec = newGoroutineExecutor()
+	s.pq = make(priorityQueue, 0)
+	s.blockedInQueues = make(map[QueueInterface]*blockedQueue)
+	heap.Init(&s.pq)
+	return s
 }
 
-func newModel() *model {
-	m := &model{}
-	m.eventChan = make(chan interface{})
-	m.pq = make(priorityQueue, 0)
-	m.queues = make(map[QueueInterface]bool)
-	m.blockedInQueues = make(map[QueueInterface]*list.List)
-	heap.Init(&m.pq)
-	return m
+// SetBackend selects how the actors are executed.
+// It should be called before any actor is registered
+func (s *Simulation) SetBackend(b Backend) {
+	if len(s.actors) > 0 {
+		panic("engine: SetBackend called after RegisterActor")
+	}
+	s.exec = newExecutor(b)
+}
+
+// RegisterActor registers a specific simulation element.
+// All actors should be registered. The actor queues are registered with the
+// simulation too
+func (s *Simulation) RegisterActor(a ActorInterface) {
+	a.init(s, len(s.actors), s.streams.nextSeed())
+	if g, ok := a.(GeneratorInterface); ok {
+		a.getActor().generator = g.IsGenerator()
+	}
+	if m, ok := a.(MonitorInterface); ok {
+		a.getActor().monitor = m.IsMonitor()
+	}
+	if act := a.getActor(); act.generator && !act.monitor {
+		s.sources++
+	}
+	s.actors = append(s.actors, a)
+}
+
+// blockedQueue keeps the block events of the actors waiting on a queue,
+// and the actors waiting for space if the queue is bounded
+type blockedQueue struct {
+	q       QueueInterface
+	idx     int
+	waiting *list.List
+	writers *list.List
+	readers int
+	dirty   bool
 }
 
-func (m *model) registerActor(a ActorInterface) {
-	a.init(m.eventChan)
-	m.actorCount++
+// registerQueue makes the simulation check q for blocked readers.
+// Queues are checked in registration order
+func (s *Simulation) registerQueue(q QueueInterface) *blockedQueue {
+	if bq, ok := s.blockedInQueues[q]; ok {
+		return bq
+	}
+	bq := &blockedQueue{q: q, idx: len(s.queues), waiting: list.New(), writers: list.New()}
+	s.blockedInQueues[q] = bq
+	s.queues = append(s.queues, bq)
+	return bq
+}
 
-	go a.Run()
+// registerReader records that an actor reads from q
+func (s *Simulation) registerReader(q QueueInterface) {
+	s.registerQueue(q).readers++
 }
 
-func (m *model) registerBlockEvent(e blockEventInterface) {
-	for _, q := range e.getQueues() {
-		if _, ok := m.blockedInQueues[q]; !ok {
-			m.blockedInQueues[q] = list.New()
+// SetOrphanCheck makes Run report the queues that hold requests but are not
+// an input queue of any actor
+func (s *Simulation) SetOrphanCheck(check bool) {
+	s.orphanCheck = check
+}
+
+// InitStats sets the interface in charge of collecting statistics.
+// This is interface is called at the end of the simulation to print the
+// collected statistics
+func (s *Simulation) InitStats(st Stats) {
+	s.bookkeeping = append(s.bookkeeping, st)
+}
+
+// AddStopCondition makes the simulation end as soon as c is done, even if
+// the threshold time is not reached yet
+func (s *Simulation) AddStopCondition(c StopCondition) {
+	s.stopConditions = append(s.stopConditions, c)
+}
+
+func (s *Simulation) stopped() bool {
+	for _, c := range s.stopConditions {
+		if c.Done() {
+			return true
 		}
-		el := m.blockedInQueues[q].PushBack(e)
-		e.addReplica(listElPair{el, m.blockedInQueues[q]})
 	}
+	return false
 }
 
-func (m *model) getTime() float64 {
-	return m.time
+// NewRand returns a new random stream derived from the simulation seed.
+// Actors get their own streams at registration; this is meant for elements
+// that are not actors, e.g. request creators
+func (s *Simulation) NewRand() *rand.Rand {
+	return s.streams.newRand()
 }
 
-func (m *model) waitActor() {
-	newEvent := <-m.eventChan
+func (s *Simulation) registerBlockEvent(e blockEventInterface) {
+	for _, q := range e.getQueues() {
+		bq := s.registerQueue(q)
+		el := bq.waiting.PushBack(e)
+		e.addReplica(listElPair{el, bq.waiting})
+		s.dirty.mark(bq)
+	}
+}
+
+// GetWindow returns the measurement window of the simulation
+func (s *Simulation) GetWindow() Window {
+	return s.window
+}
+
+// GetTime returns the current simulation time
+func (s *Simulation) GetTime() float64 {
+	return s.time
+}
+
+// pushTimer adds a timer event to the heap. Events at the same time
+// pop in the order they were pushed
+func (s *Simulation) pushTimer(e timerEventInterface) {
+	e.setSeq(s.eventSeq)
+	s.eventSeq++
+	if e.getActor().monitor {
+		s.monitorTimers++
+	}
+	heap.Push(&s.pq, e)
+}
+
+// timerRemoved is called for every timer event taken out of the heap
+func (s *Simulation) timerRemoved(e timerEventInterface) {
+	if e.getActor().monitor {
+		s.monitorTimers--
+	}
+}
+
+// handleEvent records the event an actor blocked with
+func (s *Simulation) handleEvent(newEvent interface{}) {
+	if s.draining && s.fromGenerator(newEvent) {
+		return
+	}
 	if timerE, ok := newEvent.(timerEvent); ok {
-		heap.Push(&m.pq, &timerE)
+		s.pushTimer(&timerE)
 		return
 	}
 	if blockE, ok := newEvent.(blockEvent); ok {
-		m.registerBlockEvent(&blockE)
+		s.registerBlockEvent(&blockE)
 		return
 	}
 	if linkedE, ok := newEvent.(linkedEvent); ok {
-		heap.Push(&m.pq, &linkedE)
-		m.registerBlockEvent(&linkedE)
+		s.pushTimer(&linkedE)
+		s.registerBlockEvent(&linkedE)
+		return
+	}
+	if spaceE, ok := newEvent.(spaceEvent); ok {
+		bq := s.registerQueue(spaceE.queue)
+		bq.writers.PushBack(spaceE.actor)
+		s.dirty.mark(bq)
 		return
 	}
 }
 
-func (m *model) run(threshold float64) {
-	////wait for all actors to start and add an event or block on a queue
-	for i := 0; i < m.actorCount; i++ {
-		m.waitActor()
+// Run runs the simulation for till the given threshold time.
+// Statistics only account for the measurement window, which starts after
+// the warmup time and ends cooldown time before the threshold.
+// A zero cooldown keeps the window open till the end, which is the end of
+// the drain phase if draining.
+// The simulation ends earlier if any stop condition is done, or once every
+// generator is exhausted and every request is finished.
+// Run returns a *Diagnostic if every actor got blocked on empty queues with
+// no pending timer but the ones of monitors, or if the orphan check is on and found orphan queues.
+// The statistics are printed in any case
+func (s *Simulation) Run(threshold, warmup, cooldown float64) error {
+	s.window = Window{Start: warmup, End: threshold - cooldown}
+	if s.drain && cooldown == 0 {
+		s.window.End = math.Inf(1)
+	}
+	for _, st := range s.bookkeeping {
+		st.SetWindow(s.window)
 	}
 
-	//all actors started
-	for m.time < threshold {
+	// start the actors one by one in registration order and wait for each
+	// to add an event or block on a queue, so that they never run concurrently
+	for _, a := range s.actors {
+		s.handleEvent(s.exec.start(a))
+	}
 
-		for q := range m.queues {
-			if q.Len() == 0 {
-				continue
+	//all actors started
+	var err error
+	cut := false
+	for !s.stopped() && !s.exhaustedAll() {
+		if s.time >= threshold && !cut {
+			cut = true
+			s.inFlight = s.created - s.terminated
+			s.stopReason = "cutoff"
+			if !s.drain {
+				break
 			}
+			s.stopGenerators()
+		}
 
-			// Check if none is waiting for this active queue
-			if val, ok := m.blockedInQueues[q]; ok {
-				if val.Len() == 0 {
-					continue
-				}
-			} else {
-				continue
-			}
+		s.wakeBlocked()
 
-			for e := m.blockedInQueues[q].Front(); e != nil && q.Len() > 0; e = e.Next() {
-				be := e.Value.(blockEventInterface)
-				// Remove the blockEvents for the rest of the queues if any
-				be.deactivateReplicas()
-
-				if linkedE, ok := e.Value.(*linkedEvent); ok {
-					heap.Remove(&m.pq, linkedE.timerEvent.idx)
-				}
-				be.getChannel() <- 1 // try to unblock
-				m.waitActor()
-				//m.blockedInQueues[q].Remove(e)
+		// the timers of monitors do not keep the run going
+		if s.pq.Len() == s.monitorTimers {
+			if !s.draining && !s.exhaustedAll() {
+				err = s.deadlock()
 			}
+			break
 		}
 
 		// pick event and wake up process
-		e := heap.Pop(&m.pq).(timerEventInterface)
-		m.time = e.getTime()
+		e := heap.Pop(&s.pq).(timerEventInterface)
+		s.timerRemoved(e)
+		s.time = e.getTime()
 
 		// if it's linked deactivate the blocked requests
 		if linkedE, ok := e.(*linkedEvent); ok {
 			linkedE.blockEvent.deactivateReplicas()
 		}
-		e.getChannel() <- 1
-
-		// wait till process adds event or blocks in queue
-		m.waitActor()
+		// wake up and wait till process adds event or blocks in queue
+		s.handleEvent(s.exec.resume(e.getActor()))
 	}
-	for _, s := range m.bookkeeping {
-		s.PrintStats()
+	if !cut {
+		s.inFlight = s.created - s.terminated
+		switch {
+		case s.stopped():
+			s.stopReason = "stop condition"
+		case s.exhaustedAll():
+			s.stopReason = "exhaustion"
+		default:
+			s.stopReason = "deadlock"
+		}
 	}
+	if err == nil && s.orphanCheck {
+		if orphans := s.orphanQueues(); len(orphans) > 0 {
+			err = &Diagnostic{Time: s.time, Orphans: orphans}
+		}
+	}
+	s.flushUsage()
+	s.exec.stop(s.actors)
+	s.printInFlight()
+	for _, st := range s.bookkeeping {
+		st.PrintStats(s.time)
+	}
+	if s.usageReport {
+		s.printUsage()
+	}
+	return err
 }
 
-// InitSim initialises the simulation
-func InitSim() {
-	mdl = newModel()
-}
-
-// GetTime returns the current simulation time
-func GetTime() float64 {
-	return mdl.getTime()
+// wakeBlocked wakes up the actors blocked on the dirty queues, till woken
+// actors dirty no more queues
+func (s *Simulation) wakeBlocked() {
+	for {
+		bq := s.dirty.pop(s.queues)
+		if bq == nil {
+			return
+		}
+		s.wakeReaders(bq)
+		s.wakeWriters(bq)
+		// a queue can wake one reader at a time
+		if bq.wakeable() {
+			s.dirty.mark(bq)
+		}
+	}
 }
 
-// RegisterActor registers a specific simulation element.
-// All actors should be registered
-func RegisterActor(a ActorInterface) {
-	mdl.registerActor(a)
-}
+// wakeReaders wakes up the actors blocked on a queue that got requests
+func (s *Simulation) wakeReaders(bq *blockedQueue) {
+	q := bq.q
+	for e := bq.waiting.Front(); e != nil && q.Len() > 0; e = e.Next() {
+		be := e.Value.(blockEventInterface)
+		// Remove the blockEvents for the rest of the queues if any
+		be.deactivateReplicas()
 
-// Run runs the simulation for till the given threshold time
-func Run(threshold float64) {
-	mdl.run(threshold)
+		if linkedE, ok := e.Value.(*linkedEvent); ok {
+			s.timerRemoved(heap.Remove(&s.pq, linkedE.timerEvent.idx).(timerEventInterface))
+		}
+		// try to unblock
+		be.getActor().wokenBy = q
+		s.handleEvent(s.exec.resume(be.getActor()))
+		//bq.waiting.Remove(e)
+	}
 }
 
-// InitStats sets the interface in charge of collecting statistics.
-// This is interface is called at the end of the simulation to print the
-// collected statistics
-func InitStats(s Stats) {
-	mdl.bookkeeping = append(mdl.bookkeeping, s)
+// wakeWriters wakes up the actors waiting for space in a bounded queue, in
+// the order they blocked, while there is space
+func (s *Simulation) wakeWriters(bq *blockedQueue) {
+	if bq.writers.Len() == 0 {
+		return
+	}
+	q := bq.q.(BoundedQueueInterface)
+	for bq.writers.Len() > 0 && q.Len() < q.Cap() {
+		a := bq.writers.Remove(bq.writers.Front()).(*Actor)
+		s.handleEvent(s.exec.resume(a))
+	}
 }
@@ -0,0 +1,79 @@
+package engine
+
+// Observer is notified of the simulation events. Actors are identified by
+// their id (see Actor.GetID and Simulation.GetActor) and queues by the
+// QueueInterface value. Observers are called synchronously from the
+// simulation, so they should not block
+type Observer interface {
+	// ActorBlocked is called when an actor blocks on its input queues
+	ActorBlocked(now float64, actor int, queues []QueueInterface)
+	// ActorBlockedWrite is called when an actor blocks till a bounded
+	// queue has space
+	ActorBlockedWrite(now float64, actor int, q QueueInterface)
+	// TimerScheduled is called when an actor waits till the given time
+	TimerScheduled(now, at float64, actor int)
+	// ActorWoken is called when a blocked or waiting actor resumes
+	ActorWoken(now float64, actor int)
+	// Enqueued is called when an actor writes a request to a queue
+	Enqueued(now float64, actor int, q QueueInterface, req ReqInterface)
+	// Dequeued is called when an actor reads a request from a queue
+	Dequeued(now float64, actor int, q QueueInterface, req ReqInterface)
+	// Created is called when a generator creates a request
+	Created(now float64, actor int, req ReqInterface)
+	// Terminated is called when an actor finishes a request
+	Terminated(now float64, actor int, req ReqInterface)
+}
+
+// NopObserver implements Observer doing nothing. Observers interested in
+// some of the events can embed it
+type NopObserver struct{}
+
+// ActorBlocked does nothing
+func (NopObserver) ActorBlocked(now float64, actor int, queues []QueueInterface) {}
+
+// ActorBlockedWrite does nothing
+func (NopObserver) ActorBlockedWrite(now float64, actor int, q QueueInterface) {}
+
+// TimerScheduled does nothing
+func (NopObserver) TimerScheduled(now, at float64, actor int) {}
+
+// ActorWoken does nothing
+func (NopObserver) ActorWoken(now float64, actor int) {}
+
+// Enqueued does nothing
+func (NopObserver) Enqueued(now float64, actor int, q QueueInterface, req ReqInterface) {}
+
+// Dequeued does nothing
+func (NopObserver) Dequeued(now float64, actor int, q QueueInterface, req ReqInterface) {}
+
+// Created does nothing
+func (NopObserver) Created(now float64, actor int, req ReqInterface) {}
+
+// Terminated does nothing
+func (NopObserver) Terminated(now float64, actor int, req ReqInterface) {}
+
+// AddObserver adds an observer to the simulation
+func (s *Simulation) AddObserver(o Observer) {
+	s.observers = append(s.observers, o)
+}
+
+// GetActor returns the actor with the given id
+func (s *Simulation) GetActor(id int) ActorInterface {
+	return s.actors[id]
+}
+
+func (s *Simulation) observeBlock(a *Actor, e interface{}) {
+	for _, o := range s.observers {
+		switch ev := e.(type) {
+		case timerEvent:
+			o.TimerScheduled(s.time, ev.time, a.id)
+		case blockEvent:
+			o.ActorBlocked(s.time, a.id, ev.queues)
+		case spaceEvent:
+			o.ActorBlockedWrite(s.time, a.id, ev.queue)
+		case linkedEvent:
+			o.TimerScheduled(s.time, ev.timerEvent.time, a.id)
+			o.ActorBlocked(s.time, a.id, ev.blockEvent.queues)
+		}
+	}
+}
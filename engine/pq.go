@@ -6,7 +6,11 @@ type priorityQueue []timerEventInterface
 func (pq priorityQueue) Len() int { return len(pq) }
 
 func (pq priorityQueue) Less(i, j int) bool {
-	return pq[i].getTime() < pq[j].getTime() // greater time - less priority
+	ti, tj := pq[i].getTime(), pq[j].getTime()
+	if ti != tj {
+		return ti < tj // greater time - less priority
+	}
+	return pq[i].getSeq() < pq[j].getSeq() // same time - first pushed first
 }
 
 func (pq priorityQueue) Swap(i, j int) {
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"container/heap"
+	"sort"
+	"testing"
+)
+
+func TestTimerTieOrder(t *testing.T) {
+	many := make([]float64, 100)
+	for i := range many {
+		many[i] = float64(i % 3)
+	}
+	tests := []struct {
+		name  string
+		times []float64
+	}{
+		{"same time", []float64{5, 5, 5, 5, 5}},
+		{"distinct times", []float64{4, 2, 3, 1, 0}},
+		{"mixed", []float64{3, 1, 3, 1, 2, 1, 3}},
+		{"many ties", many},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSimulation(1)
+			actors := make([]Actor, len(tt.times))
+			for i, tm := range tt.times {
+				s.pushTimer(&timerEvent{time: tm, actor: &actors[i]})
+			}
+			// by time, then in push order
+			want := make([]int, len(tt.times))
+			for i := range want {
+				want[i] = i
+			}
+			sort.SliceStable(want, func(i, j int) bool { return tt.times[want[i]] < tt.times[want[j]] })
+			for _, w := range want {
+				e := heap.Pop(&s.pq).(timerEventInterface)
+				if e.getActor() != &actors[w] {
+					t.Fatalf("popped %v at time %v, want %v", e.getSeq(), e.getTime(), w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"math/rand"
+)
+
+// deriveSeed returns the n-th seed derived from a parent seed.
+// It uses the splitmix64 finalizer so that consecutive streams are
+// decorrelated even for adjacent parent seeds
+func deriveSeed(seed int64, n uint64) int64 {
+	z := uint64(seed) + (n+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// randStreams hands out random streams derived from a single seed.
+// The same seed and the same sequence of calls give the same streams
+type randStreams struct {
+	seed  int64
+	count uint64
+}
+
+func (s *randStreams) nextSeed() int64 {
+	res := deriveSeed(s.seed, s.count)
+	s.count++
+	return res
+}
+
+func (s *randStreams) newRand() *rand.Rand {
+	return rand.New(rand.NewSource(s.nextSeed()))
+}
@@ -0,0 +1,69 @@
+package engine
+
+// Signal wakes up an actor waiting on it with WaitSignal. It lets code that
+// runs on behalf of another actor, e.g. a RequestDrain, wake an actor up
+// without writing a request to its queues. A notification that finds no
+// waiter is kept till the next wait; notifications do not add up
+type Signal struct {
+	q   signalQueue
+	sim *Simulation
+}
+
+// Notify wakes up the actor waiting on the signal
+func (sig *Signal) Notify() {
+	sig.q.set = true
+	if sig.sim != nil {
+		sig.sim.enqueued(&sig.q)
+	}
+}
+
+// signalQueue is the queue the simulation sees for a Signal. It is not
+// empty while the signal is set
+type signalQueue struct {
+	set bool
+}
+
+func (q *signalQueue) Enqueue(el ReqInterface) {
+	q.set = true
+}
+
+func (q *signalQueue) Dequeue() ReqInterface {
+	q.set = false
+	return nil
+}
+
+func (q *signalQueue) Len() int {
+	if q.set {
+		return 1
+	}
+	return 0
+}
+
+// String names the queue in diagnostics
+func (q *signalQueue) String() string {
+	return "signal"
+}
+
+// WaitSignal blocks the actor till sig is notified or for a d interval,
+// and clears the signal. It returns whether the signal was notified. If d is
+// negative there is no timeout. The time waiting is idle
+func (a *Actor) WaitSignal(sig *Signal, d float64) bool {
+	if sig.sim == nil {
+		sig.sim = a.sim
+		a.sim.registerReader(&sig.q)
+	}
+	if !sig.q.set {
+		queues := []QueueInterface{&sig.q}
+		if d < 0 {
+			a.block(blockEvent{actor: a, queues: queues})
+		} else {
+			a.block(linkedEvent{
+				timerEvent: timerEvent{time: d + a.sim.GetTime(), actor: a, reason: waitQueue},
+				blockEvent: blockEvent{actor: a, queues: queues},
+			})
+		}
+	}
+	notified := sig.q.set
+	sig.q.set = false
+	return notified
+}
@@ -0,0 +1,99 @@
+package engine_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// backends are the engine backends, named for subtests
+var backends = []struct {
+	name    string
+	backend engine.Backend
+}{
+	{"goroutine", engine.GoroutineBackend},
+	{"loop", engine.EventLoopBackend},
+}
+
+// delaySum adds up the latency of the finished requests
+type delaySum struct {
+	n   int
+	sum float64
+}
+
+func (d *delaySum) TerminateReq(req engine.ReqInterface, now float64) {
+	d.n++
+	d.sum += req.GetDelay(now)
+}
+
+func (d *delaySum) SetName(name string) {}
+
+// runQueue runs 1000 requests through 4 cores sharing a queue and returns
+// their latencies
+func runQueue(seed int64, backend engine.Backend) delaySum {
+	sim := engine.NewSimulation(seed)
+	sim.SetBackend(backend)
+	delays := &delaySum{}
+	g := blocks.NewMMRandGenerator(0.3, 0.1)
+	g.SetCreator(&blocks.SimpleReqCreator{})
+	g.SetBudget(1000)
+	q := blocks.NewQueue()
+	g.AddOutQueue(q)
+	for i := 0; i < 4; i++ {
+		p := &blocks.RTCProcessor{}
+		p.AddInQueue(q)
+		p.SetReqDrain(delays)
+		sim.RegisterActor(p)
+	}
+	sim.RegisterActor(g)
+	sim.Run(1e9, 0, 0)
+	return *delays
+}
+
+func TestConcurrentSimulations(t *testing.T) {
+	for _, b := range backends {
+		backend := b.backend
+		want := []delaySum{runQueue(1, backend), runQueue(2, backend)}
+		if want[0] == want[1] {
+			t.Fatalf("%v backend: different seeds, same latencies", b.name)
+		}
+		got := make([]delaySum, 8)
+		var wg sync.WaitGroup
+		for i := range got {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				got[i] = runQueue(int64(i%2+1), backend)
+			}(i)
+		}
+		wg.Wait()
+		for i, d := range got {
+			if d != want[i%2] {
+				t.Errorf("%v backend seed %v: concurrent run %+v, want %+v", b.name, i%2+1, d, want[i%2])
+			}
+		}
+	}
+}
+
+func TestNoGoroutineLeak(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			for i := 0; i < 20; i++ {
+				runQueue(1, b.backend)
+			}
+			// the actor goroutines may still be exiting
+			deadline := time.Now().Add(time.Second)
+			for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if n := runtime.NumGoroutine(); n > before {
+				t.Errorf("%v goroutines after 20 runs, %v before", n, before)
+			}
+		})
+	}
+}
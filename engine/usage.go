@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// WaitReason tells what an actor spends the time of a Wait on
+type WaitReason int
+
+const (
+	// WaitService is time spent serving requests
+	WaitService WaitReason = iota
+	// WaitOffload is the cost of offloading a request to another actor
+	WaitOffload
+	// WaitCtxSwitch is the context switch cost
+	WaitCtxSwitch
+	// WaitPolling is time spent polling for a condition
+	WaitPolling
+	// NumWaitReasons is the number of wait reasons
+	NumWaitReasons
+)
+
+// waitQueue is the reason of the timeout of a wait for requests. That time
+// is idle, not busy
+const waitQueue WaitReason = -1
+
+var waitReasonNames = [NumWaitReasons]string{"Service", "Offload", "CtxSwitch", "Polling"}
+
+func (r WaitReason) String() string {
+	if r < 0 || r >= NumWaitReasons {
+		return fmt.Sprintf("WaitReason(%d)", int(r))
+	}
+	return waitReasonNames[r]
+}
+
+// Usage holds the time an actor spent in the measurement window, split by
+// what the actor was doing. Idle is the time blocked on empty input queues
+// and Blocked the time blocked on full bounded queues
+type Usage struct {
+	Busy    [NumWaitReasons]float64
+	Idle    float64
+	Blocked float64
+}
+
+// BusyTime returns the time spent in Wait for any reason
+func (u Usage) BusyTime() float64 {
+	var t float64
+	for _, b := range u.Busy {
+		t += b
+	}
+	return t
+}
+
+// Utilization returns the busy fraction of the given time
+func (u Usage) Utilization(total float64) float64 {
+	return u.BusyTime() / total
+}
+
+// SetUsageReport prints the usage of every actor, but the generators, after
+// the stats
+func (s *Simulation) SetUsageReport(report bool) {
+	s.usageReport = report
+}
+
+// GetUsage returns the usage of the actor with the given id
+func (s *Simulation) GetUsage(id int) Usage {
+	return s.actors[id].getActor().usage
+}
+
+// account adds the time the actor was blocked with the event from since
+// till now, as far as it overlaps the measurement window
+func (a *Actor) account(e interface{}, since float64) {
+	w := a.sim.window
+	d := math.Min(a.sim.time, w.End) - math.Max(since, w.Start)
+	if d <= 0 {
+		return
+	}
+	switch ev := e.(type) {
+	case timerEvent:
+		a.accountWait(ev.reason, d)
+	case linkedEvent:
+		a.accountWait(ev.timerEvent.reason, d)
+	case blockEvent:
+		a.usage.Idle += d
+	case spaceEvent:
+		a.usage.Blocked += d
+	}
+}
+
+// accountWait adds d to the busy time of the reason, or to the idle time if
+// the actor was waiting for requests
+func (a *Actor) accountWait(reason WaitReason, d float64) {
+	if reason == waitQueue {
+		a.usage.Idle += d
+		return
+	}
+	a.usage.Busy[reason] += d
+}
+
+// flushUsage accounts the time of the actors still blocked at the end of
+// the simulation
+func (s *Simulation) flushUsage() {
+	for _, act := range s.actors {
+		a := act.getActor()
+		if a.blockedOn != nil {
+			a.account(a.blockedOn, a.blockedSince)
+			a.blockedOn = nil
+		}
+	}
+}
+
+func (s *Simulation) printUsage() {
+	total := s.window.Length(s.time)
+	fmt.Println("Usage")
+	fmt.Print("Actor")
+	for _, n := range waitReasonNames {
+		fmt.Printf("\t%v", n)
+	}
+	fmt.Println("\tIdle\tBlocked\tUtilization")
+	for _, act := range s.actors {
+		a := act.getActor()
+		if a.generator {
+			continue
+		}
+		fmt.Printf("%v", actorName(act))
+		for _, b := range a.usage.Busy {
+			fmt.Printf("\t%v", b)
+		}
+		fmt.Printf("\t%v\t%v\t%v\n", a.usage.Idle, a.usage.Blocked, a.usage.Utilization(total))
+	}
+}
+
+// IsBusy returns whether the actor with the given id is in a Wait. Waiting
+// for requests with a timeout is not busy
+func (s *Simulation) IsBusy(id int) bool {
+	switch e := s.actors[id].getActor().blockedOn.(type) {
+	case timerEvent:
+		return e.reason != waitQueue
+	case linkedEvent:
+		return e.timerEvent.reason != waitQueue
+	}
+	return false
+}
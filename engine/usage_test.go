@@ -0,0 +1,45 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// waiter times out waiting for requests and then serves for 3
+type waiter struct {
+	engine.Actor
+	wait func(a *waiter)
+}
+
+func (w *waiter) Run() {
+	w.wait(w)
+	w.Wait(3)
+}
+
+func TestUsageInterruptibleWait(t *testing.T) {
+	tests := []struct {
+		name string
+		wait func(a *waiter)
+		idle float64
+		busy float64
+	}{
+		{"interruptible", func(a *waiter) { a.WaitInterruptible(5) }, 5, 3},
+		{"interruptible queues", func(a *waiter) { a.WaitInterruptibleQueues(5, 0) }, 5, 3},
+		{"interruptible service", func(a *waiter) { a.WaitInterruptibleFor(5, engine.WaitService) }, 0, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := engine.NewSimulation(1)
+			w := &waiter{wait: tt.wait}
+			w.AddInQueue(blocks.NewQueue())
+			sim.RegisterActor(w)
+			sim.Run(10, 0, 0)
+			u := sim.GetUsage(w.GetID())
+			if u.Idle != tt.idle || u.BusyTime() != tt.busy {
+				t.Errorf("idle %v busy %v, want idle %v busy %v", u.Idle, u.BusyTime(), tt.idle, tt.busy)
+			}
+		})
+	}
+}
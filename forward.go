@@ -56,13 +56,19 @@ func tryAxCoreOutqueueThenFallback(p *GPCore, outQueues []engine.QueueInterface,
 	return -1
 }
 
+// blockUntilAxcoreAccepts always offloads. If the axCore queue is bounded
+// the GPCore blocks in the write till there is space, otherwise it polls
+// the queue length against outboundMax
 func blockUntilAxcoreAccepts(p *GPCore, outQueues []engine.QueueInterface, req *MultiPhaseReq) int {
 	if len(outQueues) > 1 {
 		log.Fatal("GPCore: More than one axCore is not supported")
 	}
 
+	if _, ok := outQueues[0].(engine.BoundedQueueInterface); ok {
+		return 0
+	}
 	for outQueues[0].Len() >= p.outboundMax {
-		p.Wait(p.offloadCost)
+		p.WaitFor(p.offloadCost, engine.WaitPolling)
 	}
 	return 0
 }
@@ -17,16 +17,15 @@ type GPCore struct {
 
 // determine the idx of the queue to read from <- parameterizable (maybe we just have one queue)
 // check the in queue corresponding to that idx
-// wait for the full service time of this phase and increment the phase counter
-// get the index of the outqueue to queue into
-// if the index is nil
-// 	wait for the full service time
-// otherwise
-// enqueue the request into the returned outqueue
+// run the phases of the request the processor is eligible for, starting with
+// the one read, and terminate the request after its last phase
+// before every next phase decide whether to offload it:
+// 	a phase only the accelerator is eligible for is always offloaded
+// 	a phase only the processor is eligible for is never offloaded
+// 	otherwise the forward function decides, -1 meaning run it here
 
 func (p *GPCore) Run() {
 	for {
-	read_inqueue:
 		var req engine.ReqInterface
 		inQueueIdx := p.queueChooseFunc(p.GetInQueues())
 		if inQueueIdx == -1 {
@@ -38,49 +37,57 @@ func (p *GPCore) Run() {
 		}
 		//fmt.Println("GPCore: Read from inQueueIdx: ", inQueueIdx)
 		//fmt.Println(req)
-		if multiPhaseReq, ok := req.(*MultiPhaseReq); ok {
-			curPhase := multiPhaseReq.Current
-		phase_exe:
-			if curPhase < len(multiPhaseReq.Phases) {
-				// Check if the device is in the set
-				if _, exists := multiPhaseReq.Phases[multiPhaseReq.Current].Devices[Processor]; exists {
-					// Processor is in the set
-					p.Wait(multiPhaseReq.GetServiceTime())
-					multiPhaseReq.Current++
-					multiPhaseReq.lastGPCoreIdx = p.gpCoreIdx
-					//fmt.Printf("GPCore: Finished phase %v\n", curPhase)
-				} else {
-					log.Fatalf("Error: Processor is not in the set")
-				}
-
-				// Check if We just finished the last phase
-				if multiPhaseReq.Current >= len(multiPhaseReq.Phases) {
-					//fmt.Println("GPCore: Last phase, terminating request")
-					p.reqDrain.TerminateReq(req)
-					goto read_inqueue
-				}
-
-				// Forward to the outgoing queue
-				outQueueIdx := p.gpCoreForwardFunc(p, p.GetOutQueues(), multiPhaseReq)
-				if outQueueIdx == -1 {
-					//fmt.Printf("Waiting for the full service time for phase %v\n", multiPhaseReq.Current)
-					p.Wait(multiPhaseReq.GetServiceTime())
-					multiPhaseReq.Current++
-					goto phase_exe
-				} else {
-					//fmt.Printf("Enqueueing phase %v into outQueueIdx: %v\n", multiPhaseReq.Current, outQueueIdx)
-					p.Wait(p.offloadCost)
-					p.WriteOutQueueI(req, outQueueIdx)
-				}
-			} else {
-				// Last phase, terminate the request
-				log.Fatalf("Error: Received a request that has already completed all phases")
-			}
-		} else {
+		multiPhaseReq, ok := req.(*MultiPhaseReq)
+		if !ok {
 			// Handle non-multi-phase requests
-			//fmt.Println(multiPhaseReq)
 			log.Fatalf("Error: NaiveOffloadingProcessor received a non-multi-phase request")
+		}
+		p.runPhases(multiPhaseReq)
+	}
+}
 
+// runPhases runs the phases of the request till it is offloaded or done.
+// The phase the request was read for runs here if the processor is
+// eligible for it
+func (p *GPCore) runPhases(req *MultiPhaseReq) {
+	read := true
+	for req.Current < len(req.Phases) {
+		devices := req.Phases[req.Current].Devices
+		_, cpu := devices[Processor]
+		_, acc := devices[Accelerator]
+		if !cpu && !acc {
+			log.Fatalf("Error: no device is eligible for phase %v", req.Current)
 		}
+		if acc && !(read && cpu) {
+			if outQueueIdx := p.offloadQueue(req, cpu); outQueueIdx != -1 {
+				//fmt.Printf("Enqueueing phase %v into outQueueIdx: %v\n", req.Current, outQueueIdx)
+				p.WaitFor(p.offloadCost, engine.WaitOffload)
+				p.WriteOutQueueIBlocking(req, outQueueIdx)
+				return
+			}
+		}
+		p.Wait(req.GetServiceTime())
+		req.Current++
+		req.lastGPCoreIdx = p.gpCoreIdx
+		read = false
+	}
+	//fmt.Println("GPCore: Last phase, terminating request")
+	p.terminate(req)
+}
+
+// offloadQueue returns the out queue to offload the current phase to, or -1
+// to run it here. A phase the processor is not eligible for is offloaded
+// even if the forward function falls back
+func (p *GPCore) offloadQueue(req *MultiPhaseReq, cpu bool) int {
+	if p.GetOutQueueCount() == 0 {
+		if !cpu {
+			log.Fatalf("Error: no accelerator for phase %v", req.Current)
+		}
+		return -1
+	}
+	outQueueIdx := p.gpCoreForwardFunc(p, p.GetOutQueues(), req)
+	if outQueueIdx == -1 && !cpu {
+		return blockUntilAxcoreAccepts(p, p.GetOutQueues(), req)
 	}
+	return outQueueIdx
 }
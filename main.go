@@ -1,14 +1,373 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"strings"
 
 	"github.com/neel-patel-1/xmp_sched_sim/blocks"
 	"github.com/neel-patel-1/xmp_sched_sim/engine"
 )
 
+// ciMinBatches is the number of batches needed before checking the
+// confidence interval stop condition
+const ciMinBatches = 10
+
+// simOptions holds the engine settings shared by every topology
+type simOptions struct {
+	seed        int64
+	backend     engine.Backend
+	warmup      float64
+	cooldown    float64
+	stopCount   int
+	stopCI      float64
+	ciBatch     int
+	orphans     bool
+	drain       bool
+	usage       bool
+	queues      bool
+	trace       *bufio.Writer
+	interval    float64
+	service     string
+	arrival     string
+	dispatch    string
+	stale       float64
+	arrivals    float64
+	profile     string
+	clients     int
+	batch       string
+	batchSpread bool
+	replay      string
+	replayLoop  bool
+	replayScale float64
+	budget      int
+	srcArrivals specList
+	srcServices specList
+	phases      [3]string
+	boundedAx   bool
+	samples     *bufio.Writer
+}
+
+// specList is a flag that can be given many times, keeping every value
+type specList []string
+
+func (l *specList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *specList) Set(spec string) error {
+	*l = append(*l, spec)
+	return nil
+}
+
+// generators are the request sources of a topology, all feeding the same
+// queues
+type generators []engine.ActorInterface
+
+// AddOutQueue adds the queue to every generator
+func (gs generators) AddOutQueue(q engine.QueueInterface) {
+	for _, g := range gs {
+		g.AddOutQueue(q)
+	}
+}
+
+// register registers every generator with the simulation, once it checked
+// that the generator dispatch policy can feed its out queues
+func (gs generators) register(sim *engine.Simulation) {
+	for _, g := range gs {
+		if d, ok := g.(interface{ CheckDispatch() error }); ok {
+			if err := d.CheckDispatch(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		sim.RegisterActor(g)
+	}
+}
+
+func (o simOptions) newSimulation() *engine.Simulation {
+	sim := engine.NewSimulation(o.seed)
+	sim.SetBackend(o.backend)
+	sim.SetOrphanCheck(o.orphans)
+	sim.SetDrain(o.drain)
+	sim.SetUsageReport(o.usage)
+	if o.trace != nil {
+		sim.AddObserver(blocks.NewTracer(o.trace))
+	}
+	return sim
+}
+
+// run runs the simulation till duration and exits with the diagnostic if
+// the simulation got stuck. With a budget the run ends once the generators
+// are exhausted, whatever the duration
+func (o simOptions) run(sim *engine.Simulation, duration float64) {
+	if o.budget > 0 {
+		duration = math.Inf(1)
+	}
+	if o.queues {
+		m := blocks.NewQueueMonitor()
+		sim.AddObserver(m)
+		sim.InitStats(m)
+	}
+	if o.arrivals > 0 {
+		m := blocks.NewArrivalMonitor(o.arrivals)
+		sim.AddObserver(m)
+		sim.InitStats(m)
+	}
+	err := sim.Run(duration, o.warmup, o.cooldown)
+	if o.trace != nil {
+		o.trace.Flush()
+	}
+	if o.samples != nil {
+		o.samples.Flush()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newGenerator returns the generators of the multi core topologies and the
+// drain their processors should hand finished requests to. Every generator
+// stops after creating --budget requests. With --source_interarrival and
+// --source_service there is an open loop generator per pair, tagging its
+// requests with its index. Otherwise there is a single generator, built by
+// newSource
+func (o simOptions) newGenerator(sim *engine.Simulation, stats *blocks.AllKeeper, creator *ThreePhaseReqCreator,
+	genType int, lambda, mu float64) (generators, blocks.RequestDrain) {
+	if o.replay != "" && o.phases != [3]string{} {
+		log.Fatal("Error: --phase_*_service need generated requests, a trace has its own phase service times")
+	}
+	if len(o.srcArrivals) == 0 && len(o.srcServices) == 0 {
+		g, drain := o.newSource(sim, stats, o.phaseCreator(creator), genType, lambda, mu)
+		if err := o.setBudget(g); err != nil {
+			log.Fatal(err)
+		}
+		return generators{g}, drain
+	}
+	if len(o.srcArrivals) != len(o.srcServices) {
+		log.Fatal("Error: every --source_interarrival needs a --source_service")
+	}
+	if o.replay != "" || o.profile != "" || o.clients > 0 {
+		log.Fatal("Error: --source_interarrival and --source_service need open loop generators")
+	}
+	gs := make(generators, len(o.srcArrivals))
+	for i := range gs {
+		arrival, err := blocks.ParseDistribution(o.srcArrivals[i])
+		if err != nil {
+			log.Fatal(err)
+		}
+		service, err := blocks.ParseDistribution(o.srcServices[i])
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := blocks.NewComposedGenerator(arrival, service, o.newDispatch())
+		g.SetCreator(o.phaseCreator(creator))
+		g.SetSource(i)
+		g.SetBudget(o.budget)
+		o.setBatch(g)
+		gs[i] = g
+	}
+	return gs, stats
+}
+
+// newSource returns the single generator of the multi core topologies and
+// the drain its processors should hand finished requests to. The
+// service time distribution is selected by genType, unless given with
+// --service, and the interarrival time is exponential with rate lambda,
+// unless given with --interarrival. Out queues are fed randomly, unless a
+// policy is given with --dispatch. With --load_profile the arrival rate
+// follows the profile instead. With --clients the generator is closed loop
+// and the interarrival distribution is the think time of each client. With
+// --batch every open loop arrival is a batch of requests. With
+// --replay the requests of a trace are replayed instead
+func (o simOptions) newSource(sim *engine.Simulation, stats *blocks.AllKeeper, creator *ThreePhaseReqCreator,
+	genType int, lambda, mu float64) (engine.ActorInterface, blocks.RequestDrain) {
+	if o.replay != "" {
+		return o.newReplayGenerator(), stats
+	}
+
+	var service, arrival blocks.Distribution
+	switch genType {
+	case 0:
+		service = blocks.NewExponDistr(mu)
+	case 1:
+		service = blocks.NewDeterministicDistr(1 / mu)
+	case 2:
+		service = blocks.NewBiDistr(1, 10*(1/mu-0.9), 0.9)
+	case 3:
+		service = blocks.NewBiDistr(1, 1000*(1/mu-0.999), 0.999)
+	}
+	arrival = blocks.NewExponDistr(lambda)
+
+	var err error
+	if o.service != "" {
+		service, err = blocks.ParseDistribution(o.service)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if o.arrival != "" {
+		arrival, err = blocks.ParseDistribution(o.arrival)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if service == nil {
+		log.Fatalf("Error: unknown genType %v", genType)
+	}
+	if o.batch != "" && o.clients > 0 {
+		log.Fatal("Error: --batch needs an open loop generator")
+	}
+	if o.dispatch == "" && o.profile == "" && o.clients == 0 {
+		g := blocks.NewRandGenerator(arrival, service)
+		g.SetCreator(creator)
+		o.setBatch(g)
+		return g, stats
+	}
+	dispatch := o.newDispatch()
+	if o.profile != "" {
+		profile, err := blocks.ParseRateProfile(o.profile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := blocks.NewProfileGenerator(profile, service, dispatch)
+		g.SetCreator(creator)
+		o.setBatch(g)
+		return g, stats
+	}
+	if o.clients > 0 {
+		g := blocks.NewClosedLoopGenerator(o.clients, arrival, service, dispatch)
+		g.SetCreator(creator)
+		g.SetReqDrain(stats)
+		sim.InitStats(g)
+		return g, g
+	}
+	g := blocks.NewComposedGenerator(arrival, service, dispatch)
+	g.SetCreator(creator)
+	o.setBatch(g)
+	return g, stats
+}
+
+// checkFixedGenerator exits if the generator flags are given to a topology
+// with a fixed deterministic generator, which would ignore them
+func (o simOptions) checkFixedGenerator() {
+	if o.service != "" || o.arrival != "" || o.dispatch != "" || o.profile != "" || o.clients > 0 ||
+		o.batch != "" || o.replay != "" || len(o.srcArrivals) > 0 || len(o.srcServices) > 0 {
+		log.Fatal("Error: this topology has a fixed deterministic generator. --service, --interarrival, --dispatch, " +
+			"--load_profile, --clients, --batch, --replay and --source_* need topologies 2 to 5")
+	}
+}
+
+// phaseCreator returns a copy of creator drawing the service time of every
+// phase given with --phase_one_service, --phase_two_service or
+// --phase_three_service from that distribution instead of its ratio. Each
+// generator needs its own copy, as it gives the distributions their
+// random streams
+func (o simOptions) phaseCreator(creator *ThreePhaseReqCreator) *ThreePhaseReqCreator {
+	c := *creator
+	for i, spec := range o.phases {
+		if spec == "" {
+			continue
+		}
+		d, err := blocks.ParseDistribution(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.SetPhaseDistr(i, d)
+	}
+	return &c
+}
+
+// setBudget makes the generator stop after creating --budget requests
+func (o simOptions) setBudget(g engine.ActorInterface) error {
+	if o.budget == 0 {
+		return nil
+	}
+	b, ok := g.(interface{ SetBudget(int) })
+	if !ok {
+		return fmt.Errorf("Error: %T does not support --budget", g)
+	}
+	b.SetBudget(o.budget)
+	return nil
+}
+
+// newDispatch returns the --dispatch policy of a generator, random by
+// default
+func (o simOptions) newDispatch() blocks.DispatchPolicy {
+	if o.dispatch == "" {
+		return &blocks.RandomDispatch{}
+	}
+	dispatch, err := blocks.ParseDispatchPolicy(o.dispatch, o.stale)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return dispatch
+}
+
+// setBatch makes the generator arrivals batches of the --batch size
+func (o simOptions) setBatch(g interface {
+	SetBatch(blocks.Distribution, bool)
+}) {
+	if o.batch == "" {
+		return
+	}
+	size, err := blocks.ParseDistribution(o.batch)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.SetBatch(size, o.batchSpread)
+}
+
+// newReplayGenerator returns a generator replaying the --replay trace
+func (o simOptions) newReplayGenerator() *blocks.TraceGenerator {
+	records, err := blocks.LoadTrace(o.replay, traceDevices)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := blocks.NewTraceGenerator(records, TraceReqCreator{}, o.newDispatch())
+	if err := g.SetTimeScale(o.replayScale); err != nil {
+		log.Fatal(err)
+	}
+	if err := g.SetLoop(o.replayLoop); err != nil {
+		log.Fatal(err)
+	}
+	return g
+}
+
+// newSampler returns a sampler counting the completions of stats. It only
+// runs if added with addSampler and a sample file was given
+func (o simOptions) newSampler(stats blocks.Counter) *blocks.Sampler {
+	return blocks.NewSampler(o.interval, stats, o.samples)
+}
+
+// addSampler registers the sampler once its queues and actors are watched
+func (o simOptions) addSampler(sim *engine.Simulation, s *blocks.Sampler) {
+	if o.samples == nil {
+		return
+	}
+	sim.RegisterActor(s)
+	sim.InitStats(s)
+}
+
+// initStats makes the simulation stop early on the requests accounted by
+// stats, and makes stats report per load phase with a load profile and per
+// source with many generators
+func (o simOptions) initStats(sim *engine.Simulation, stats *blocks.AllKeeper) {
+	stats.SetByLoadPhase(o.profile != "")
+	stats.SetBySource(len(o.srcArrivals) > 1)
+	if o.stopCount > 0 {
+		sim.AddStopCondition(blocks.NewCountStop(stats, o.stopCount))
+	}
+	if o.stopCI > 0 {
+		b := blocks.NewBatchMeans(o.ciBatch)
+		stats.SetBatchMeans(b)
+		sim.AddStopCondition(blocks.NewCIStop(b, o.stopCI, ciMinBatches))
+	}
+}
+
 type mpProcessor struct {
 	engine.Actor
 	reqDrain        blocks.RequestDrain
@@ -28,6 +387,19 @@ func (p *mpProcessor) SetCtxCost(cost float64) {
 	p.ctxCost = cost
 }
 
+// terminate reports the request as finished and hands it to the drain
+func (p *mpProcessor) terminate(req engine.ReqInterface) {
+	p.ReportTermination(req)
+	p.reqDrain.TerminateReq(req, p.GetTime())
+}
+
+// switchContext waits for the context switch cost, if any
+func (p *mpProcessor) switchContext() {
+	if p.ctxCost > 0 {
+		p.WaitFor(p.ctxCost, engine.WaitCtxSwitch)
+	}
+}
+
 func (p *mpProcessor) SetOffloadCost(cost float64) {
 	p.offloadCost = cost
 }
@@ -54,7 +426,8 @@ func (p *RTCMPProcessor) Run() {
 	for {
 		req := p.ReadInQueue()
 		actualServiceTime := req.GetServiceTime() / p.speedup
-		p.Wait(actualServiceTime + p.ctxCost)
+		p.switchContext()
+		p.Wait(actualServiceTime)
 		if multiPhaseReq, ok := req.(*MultiPhaseReq); ok {
 			if multiPhaseReq.Current < len(multiPhaseReq.Phases)-1 {
 				// Move to the next phase
@@ -64,7 +437,7 @@ func (p *RTCMPProcessor) Run() {
 				p.WriteOutQueueI(req, outQueueIdx)
 			} else {
 				// Last phase, terminate the request
-				p.reqDrain.TerminateReq(req)
+				p.terminate(req)
 			}
 		} else {
 			// Handle non-multi-phase requests
@@ -76,29 +449,26 @@ func (p *RTCMPProcessor) Run() {
 
 func multi_gpcore_multi_axcore_multi_centralized(duration float64, speedup float64,
 	num_cores int, num_accelerators int, axCoreQueueSize int, lambda, mu float64, genType int,
-	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64) {
+	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64, opts simOptions) {
 
-	engine.InitSim()
+	sim := opts.newSimulation()
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
-
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
-	}
-	g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio})
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
+
+	g, drain := opts.newGenerator(sim, stats, &ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio}, genType, lambda, mu)
 	q := blocks.NewQueue()
+	q.SetLabel("arrival_q")
 	g.AddOutQueue(q)
 
 	ax_q := blocks.NewQueue()
+	ax_q.SetLabel("ax_q")
 	post_q := blocks.NewQueue()
+	post_q.SetLabel("post_q")
+
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q, ax_q, post_q)
 
 	for j := 0; j < num_accelerators; j++ {
 		axCore := &AXCore{}
@@ -106,7 +476,8 @@ func multi_gpcore_multi_axcore_multi_centralized(duration float64, speedup float
 		axCore.speedup = speedup
 		axCore.AddInQueue(ax_q)
 		axCore.AddOutQueue(post_q)
-		engine.RegisterActor(axCore)
+		sim.RegisterActor(axCore)
+		sampler.WatchActor(fmt.Sprintf("axcore %d", j), axCore)
 	}
 
 	for i := 0; i < num_cores; i++ {
@@ -118,56 +489,58 @@ func multi_gpcore_multi_axcore_multi_centralized(duration float64, speedup float
 		gpCore.AddInQueue(post_q)
 		gpCore.AddOutQueue(ax_q)
 		gpCore.AddInQueue(q)
-		gpCore.SetReqDrain(stats)
-		engine.RegisterActor(gpCore)
+		gpCore.SetReqDrain(drain)
+		sim.RegisterActor(gpCore)
+		sampler.WatchActor(fmt.Sprintf("gpcore %d", i), gpCore)
 	}
 
-	engine.RegisterActor(g)
+	g.register(sim)
+	opts.addSampler(sim, sampler)
 
 	fmt.Printf("Cores:%d\tAccelerators:%d\tMu:%f\tLambda:%f\taxCoreQueueSize:%d\taxCoreSpeedup:%f\tgenType:%d\tphase_one_ratio:%f\tphase_two_ratio:%f\tphase_three_ratio:%f\n", num_cores, num_accelerators, mu, lambda, axCoreQueueSize, speedup, genType, phase_one_ratio, phase_two_ratio, phase_three_ratio)
-	engine.Run(duration)
+	opts.run(sim, duration)
 
 }
 
 func multi_gpcore_multi_axcore_prefn_centralized_axfn_centralized_postfn_returntosender(duration float64, speedup float64,
 	num_cores int, num_accelerators int, axCoreQueueSize int, lambda, mu float64, genType int,
-	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64) {
+	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64, opts simOptions) {
 
-	engine.InitSim()
+	sim := opts.newSimulation()
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
-
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
-	}
-	g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio})
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
+
+	g, drain := opts.newGenerator(sim, stats, &ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio}, genType, lambda, mu)
 	q := blocks.NewQueue()
+	q.SetLabel("arrival_q")
 	g.AddOutQueue(q)
 
 	ax_q := blocks.NewQueue()
+	ax_q.SetLabel("ax_q")
 
 	var post_qs = make([]engine.QueueInterface, num_cores)
 
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q, ax_q)
+
 	for i := 0; i < num_cores; i++ {
 		gpCore := &GPCore{}
 		gpCore.outboundMax = axCoreQueueSize
 		gpCore.queueChooseFunc = firstNonEmptyQueue
 		gpCore.gpCoreForwardFunc = tryAxCoreOutqueueThenFallback
 		gpCore.gpCoreIdx = i
-		post_qs[i] = blocks.NewQueue()
+		post_q := blocks.NewQueue()
+		post_q.SetLabel(fmt.Sprintf("post_q %d", i))
+		post_qs[i] = post_q
+		sampler.WatchQueue(post_q)
 		gpCore.AddInQueue(post_qs[i])
 		gpCore.AddOutQueue(ax_q)
 		gpCore.AddInQueue(q)
-		gpCore.SetReqDrain(stats)
-		engine.RegisterActor(gpCore)
+		gpCore.SetReqDrain(drain)
+		sim.RegisterActor(gpCore)
+		sampler.WatchActor(fmt.Sprintf("gpcore %d", i), gpCore)
 	}
 
 	for j := 0; j < num_accelerators; j++ {
@@ -178,59 +551,70 @@ func multi_gpcore_multi_axcore_prefn_centralized_axfn_centralized_postfn_returnt
 			axCore.AddOutQueue(post_qs[i])
 		}
 		axCore.AddInQueue(ax_q)
-		engine.RegisterActor(axCore)
+		sim.RegisterActor(axCore)
+		sampler.WatchActor(fmt.Sprintf("axcore %d", j), axCore)
 	}
 
-	engine.RegisterActor(g)
+	g.register(sim)
+	opts.addSampler(sim, sampler)
 
 	fmt.Printf("Cores:%d\tAccelerators:%d\tMu:%f\tLambda:%f\taxCoreQueueSize:%d\taxCoreSpeedup:%f\tgenType:%d\tphase_one_ratio:%f\tphase_two_ratio:%f\tphase_three_ratio:%f\n", num_cores, num_accelerators, mu, lambda, axCoreQueueSize, speedup, genType, phase_one_ratio, phase_two_ratio, phase_three_ratio)
-	engine.Run(duration)
+	opts.run(sim, duration)
 
 }
 
 func multi_gpcore_multi_axcore_three_phase(duration float64, speedup float64,
 	num_cores int, num_accelerators int, axCoreQueueSize int, lambda, mu float64, genType int,
 	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64, axCoreForwardFunc ForwardDecisionProcedure,
-	gpCoreForwardFunc gpCoreForwardDecisionProcedure, gpCoreQueueChooseFunc QueueChooseProcedure) {
+	gpCoreForwardFunc gpCoreForwardDecisionProcedure, gpCoreQueueChooseFunc QueueChooseProcedure, opts simOptions) {
 
-	engine.InitSim()
+	sim := opts.newSimulation()
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
-
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
-	}
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
+
 	// g = blocks.NewDDGenerator(1/lambda, 1/mu)
-	g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio})
+	g, drain := opts.newGenerator(sim, stats, &ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio}, genType, lambda, mu)
 	q := blocks.NewQueue()
+	q.SetLabel("arrival_q")
 	c_post_q := blocks.NewQueue()
+	c_post_q.SetLabel("c_post_q")
 	g.AddOutQueue(q)
 
-	ax_q := blocks.NewQueue()
+	// ax_q is only bounded when GPCores offload with blockUntilAxcoreAccepts,
+	// so that they block on it while it is full
+	var ax_q interface {
+		engine.QueueInterface
+		SetLabel(string)
+	} = blocks.NewQueue()
+	if opts.boundedAx {
+		ax_q = blocks.NewBoundedQueue(axCoreQueueSize)
+	}
+	ax_q.SetLabel("ax_q")
 
 	var post_qs = make([]engine.QueueInterface, num_cores)
 
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q, ax_q, c_post_q)
+
 	for i := 0; i < num_cores; i++ {
 		gpCore := &GPCore{}
 		gpCore.outboundMax = axCoreQueueSize
 		gpCore.queueChooseFunc = gpCoreQueueChooseFunc
 		gpCore.gpCoreForwardFunc = gpCoreForwardFunc
 		gpCore.gpCoreIdx = i
-		post_qs[i] = blocks.NewQueue()
+		post_q := blocks.NewQueue()
+		post_q.SetLabel(fmt.Sprintf("post_q %d", i))
+		post_qs[i] = post_q
+		sampler.WatchQueue(post_q)
 		gpCore.AddInQueue(post_qs[i])
 		gpCore.AddInQueue(c_post_q)
 		gpCore.AddOutQueue(ax_q)
 		gpCore.AddInQueue(q)
-		gpCore.SetReqDrain(stats)
-		engine.RegisterActor(gpCore)
+		gpCore.SetReqDrain(drain)
+		sim.RegisterActor(gpCore)
+		sampler.WatchActor(fmt.Sprintf("gpcore %d", i), gpCore)
 	}
 
 	for j := 0; j < num_accelerators; j++ {
@@ -243,13 +627,15 @@ func multi_gpcore_multi_axcore_three_phase(duration float64, speedup float64,
 			axCore.AddOutQueue(post_qs[i])
 		}
 		axCore.AddInQueue(ax_q)
-		engine.RegisterActor(axCore)
+		sim.RegisterActor(axCore)
+		sampler.WatchActor(fmt.Sprintf("axcore %d", j), axCore)
 	}
 
-	engine.RegisterActor(g)
+	g.register(sim)
+	opts.addSampler(sim, sampler)
 
 	fmt.Printf("Cores:%d\tAccelerators:%d\tMu:%f\tLambda:%f\taxCoreQueueSize:%d\taxCoreSpeedup:%f\tgenType:%d\tphase_one_ratio:%f\tphase_two_ratio:%f\tphase_three_ratio:%f\n", num_cores, num_accelerators, mu, lambda, axCoreQueueSize, speedup, genType, phase_one_ratio, phase_two_ratio, phase_three_ratio)
-	engine.Run(duration)
+	opts.run(sim, duration)
 
 }
 
@@ -262,13 +648,46 @@ func main() {
 	var bufferSize = flag.Int("buffersize", 32, "size of each axCore's buffer")
 	var num_cores = flag.Int("num_cores", 16, "number of cores")
 	var num_accelerators = flag.Int("num_accelerators", 8, "number of accelerators")
+	var seed = flag.Int64("seed", 1, "simulation random seed")
+	var backend = flag.Int("backend", 0, "engine backend: 0 goroutine per actor, 1 single-threaded event loop")
+	var warmup = flag.Float64("warmup", 0, "time before stats collection starts")
+	var cooldown = flag.Float64("cooldown", 0, "time before the end of the experiment when stats collection stops")
+	var stopCount = flag.Int("stop_count", 0, "stop after this many completed requests, 0 to disable")
+	var stopCI = flag.Float64("stop_ci", 0, "stop when the relative CI95 half-width of the mean and 99th latency drops below this, 0 to disable")
+	var ciBatch = flag.Int("ci_batch", 1000, "requests per batch for the batch means confidence intervals")
+	var checkOrphans = flag.Bool("check_orphans", false, "report queues holding requests that no actor reads")
+	var drain = flag.Bool("drain", false, "stop the generators at the duration and let the processors finish the requests in flight")
+	var usage = flag.Bool("usage", false, "report the busy time of every actor by reason and its idle time")
+	var queueStats = flag.Bool("queue_stats", false, "report the occupancy of every queue")
+	var service = flag.String("service", "", "service time distribution spec, e.g. exp:0.1, bimodal:1,9.1,0.9 or file:trace.csv. Overrides genType")
+	var interarrival = flag.String("interarrival", "", "interarrival time distribution spec, e.g. exp:0.005, mmpp2:l1,l2,r12,r21 or mmpp:n,l1..ln,r11..rnn for an n state MMPP. Overrides lambda")
+	var dispatch = flag.String("dispatch", "", "generator dispatch policy: random, rr, jsq, pod:d, lwl or weighted:w1,w2,...")
+	var dispatchStale = flag.Float64("dispatch_stale", 0, "age of the queue information the dispatch policy sees, 0 for up to date")
+	var arrivalStats = flag.Float64("arrival_stats", 0, "report the arrival rate and the index of dispersion of the arrival counts in intervals of this length, 0 to disable")
+	var loadProfile = flag.String("load_profile", "", "time varying arrival rate, linear:t0,r0,t1,r1,... or sin:mean,amplitude,period,phases with non-negative rates, not all zero. The generator stops once the rate stays zero. Overrides lambda and interarrival")
+	var clients = flag.Int("clients", 0, "closed loop clients, each thinking for an interarrival time between requests, 0 for open loop")
+	var batch = flag.String("batch", "", "batch size distribution spec, rounded up, e.g. det:4 or exp:0.25. Every arrival is a batch of requests arriving at once")
+	var batchSpread = flag.Bool("batch_spread", false, "dispatch every request of a batch on its own instead of sending the batch to one queue")
+	var replay = flag.String("replay", "", "CSV or JSONL request trace to replay instead of generating requests")
+	var replayLoop = flag.Bool("replay_loop", false, "replay the trace forever")
+	var replayScale = flag.Float64("replay_scale", 1, "factor the trace arrival times are multiplied by")
+	var budget = flag.Int("budget", 0, "requests each generator creates before it stops, 0 for no limit. The simulation ends once every generator stopped and every request finished, ignoring duration and cooldown")
+	var srcArrivals, srcServices specList
+	flag.Var(&srcArrivals, "source_interarrival", "interarrival time distribution spec of an extra generator. Can be given many times, once per generator, each with a --source_service")
+	flag.Var(&srcServices, "source_service", "service time distribution spec of an extra generator. Can be given many times, once per generator")
+	var trace = flag.String("trace", "", "file to write the simulation event trace to")
+	var sampleInterval = flag.Float64("sample_interval", 1000, "time between samples of the queue lengths and core states")
+	var sampleFile = flag.String("samples", "", "CSV file to write the periodic samples to")
 
 	var phase_one_ratio = flag.Float64("phase_one_ratio", 0.25, "phase one ratio")
 	var phase_two_ratio = flag.Float64("phase_two_ratio", 0.5, "phase two ratio")
 	var phase_three_ratio = flag.Float64("phase_three_ratio", 0.25, "phase three ratio")
+	var phase_one_service = flag.String("phase_one_service", "", "phase one service time distribution spec, e.g. exp:0.4. Overrides phase_one_ratio")
+	var phase_two_service = flag.String("phase_two_service", "", "phase two service time distribution spec. Overrides phase_two_ratio")
+	var phase_three_service = flag.String("phase_three_service", "", "phase three service time distribution spec. Overrides phase_three_ratio")
 	var speedup = flag.Float64("speedup", 1.0, "speedup factor")
 
-	var gpcore_offload_style = flag.Int("gpcore_offload_style", 0, "gpcore offload style")
+	var gpcore_offload_style = flag.Int("gpcore_offload_style", 0, "gpcore offload style: 0 offload while the axCore queue holds less than buffersize requests, else run locally, 1 block on a buffersize bounded axCore queue")
 	var axcore_notify_recipient = flag.Int("axcore_notify_recipient", 0, "axcore notify recipient")
 	var gpcore_input_queue_selector = flag.Int("gpcore_input_queue_selector", 0, "gpcore input queue selector")
 
@@ -279,21 +698,69 @@ func main() {
 	flag.Parse()
 	fmt.Printf("Selected topology: %v\n", *topo)
 
+	opts := simOptions{
+		seed:        *seed,
+		backend:     engine.Backend(*backend),
+		warmup:      *warmup,
+		cooldown:    *cooldown,
+		stopCount:   *stopCount,
+		stopCI:      *stopCI,
+		ciBatch:     *ciBatch,
+		orphans:     *checkOrphans,
+		drain:       *drain,
+		usage:       *usage,
+		queues:      *queueStats,
+		service:     *service,
+		arrival:     *interarrival,
+		dispatch:    *dispatch,
+		stale:       *dispatchStale,
+		arrivals:    *arrivalStats,
+		profile:     *loadProfile,
+		clients:     *clients,
+		batch:       *batch,
+		batchSpread: *batchSpread,
+		replay:      *replay,
+		replayLoop:  *replayLoop,
+		replayScale: *replayScale,
+		budget:      *budget,
+		srcArrivals: srcArrivals,
+		srcServices: srcServices,
+		phases:      [3]string{*phase_one_service, *phase_two_service, *phase_three_service},
+		boundedAx:   *gpcore_offload_style == 1,
+	}
+	if *trace != "" {
+		f, err := os.Create(*trace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		opts.trace = bufio.NewWriter(f)
+	}
+	if *sampleFile != "" {
+		f, err := os.Create(*sampleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		opts.interval = *sampleInterval
+		opts.samples = bufio.NewWriter(f)
+	}
+
 	if *topo == 0 {
 		// single_core_deterministic(*lambda, *mu, *duration)
-		chained_cores_multi_phase_deterministic(*lambda, *mu, *duration, 2)
+		chained_cores_multi_phase_deterministic(*lambda, *mu, *duration, 2, opts)
 	}
 	if *topo == 1 {
-		fallback_gpcore_core_three_phase_single(*lambda, *mu, *duration, 2, *num_cores, *num_accelerators, *bufferSize)
+		fallback_gpcore_core_three_phase_single(*lambda, *mu, *duration, 2, *num_cores, *num_accelerators, *bufferSize, opts)
 	}
 	if *topo == 2 {
-		fallback_multi_gpcore_axcore_three_phase(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio)
+		fallback_multi_gpcore_axcore_three_phase(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio, opts)
 	}
 	if *topo == 3 {
-		multi_gpcore_multi_axcore_multi_centralized(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio)
+		multi_gpcore_multi_axcore_multi_centralized(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio, opts)
 	}
 	if *topo == 4 {
-		multi_gpcore_multi_axcore_prefn_centralized_axfn_centralized_postfn_returntosender(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio)
+		multi_gpcore_multi_axcore_prefn_centralized_axfn_centralized_postfn_returntosender(*duration, *speedup, *num_cores, *num_accelerators, *bufferSize, *lambda, *mu, *genType, *phase_one_ratio, *phase_two_ratio, *phase_three_ratio, opts)
 	}
 
 	if *gpcore_offload_style == 0 {
@@ -336,6 +803,7 @@ func main() {
 			axCoreForwardFunc,
 			gpCoreForwardFunc,
 			gpCoreQueueChooseFunc,
+			opts,
 		)
 	}
 
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+
 	"github.com/neel-patel-1/xmp_sched_sim/blocks"
 	"github.com/neel-patel-1/xmp_sched_sim/engine"
 )
@@ -19,6 +21,7 @@ type Phase struct {
 
 type MultiPhaseReq struct {
 	blocks.Request
+	Class         int
 	Phases        []Phase
 	Current       int
 	lastGPCoreIdx int
@@ -27,15 +30,15 @@ type MultiPhaseReq struct {
 type MultiPhaseReqCreator struct{}
 
 // NewRequest returns a new MultiPhaseReq
-func (m MultiPhaseReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
+func (m MultiPhaseReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
 	return &MultiPhaseReq{
 		Phases: []Phase{
 			{
-				Request: blocks.Request{InitTime: engine.GetTime(), ServiceTime: serviceTime},
+				Request: blocks.Request{InitTime: now, ServiceTime: serviceTime},
 				Devices: map[DeviceType]struct{}{Processor: {}},
 			},
 			{
-				Request: blocks.Request{InitTime: engine.GetTime(), ServiceTime: serviceTime},
+				Request: blocks.Request{InitTime: now, ServiceTime: serviceTime},
 				Devices: map[DeviceType]struct{}{
 					Processor:   {},
 					Accelerator: {},
@@ -50,13 +53,30 @@ type ThreePhaseReqCreator struct {
 	phase_one_ratio   float64
 	phase_two_ratio   float64
 	phase_three_ratio float64
+	phaseDistrs       [3]blocks.Distribution
 }
 
-func (m ThreePhaseReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &MultiPhaseReq{
+// SetPhaseDistr draws the service time of phase i from d instead of the
+// phase ratio of the generator service time. The generator owning the
+// creator gives d its random stream, so d should not be shared
+func (m *ThreePhaseReqCreator) SetPhaseDistr(i int, d blocks.Distribution) {
+	m.phaseDistrs[i] = d
+}
+
+// InitRand gives each phase distribution its own random stream
+func (m *ThreePhaseReqCreator) InitRand(newRand func() *rand.Rand) {
+	for _, d := range m.phaseDistrs {
+		if d != nil {
+			d.SetRand(newRand())
+		}
+	}
+}
+
+func (m ThreePhaseReqCreator) NewRequest(now, serviceTime float64) engine.ReqInterface {
+	req := &MultiPhaseReq{
 		Phases: []Phase{
 			{
-				Request: blocks.Request{InitTime: engine.GetTime(), ServiceTime: serviceTime * m.phase_one_ratio},
+				Request: blocks.Request{InitTime: now, ServiceTime: serviceTime * m.phase_one_ratio},
 				Devices: map[DeviceType]struct{}{Processor: {}},
 			},
 			{
@@ -70,10 +90,44 @@ func (m ThreePhaseReqCreator) NewRequest(serviceTime float64) engine.ReqInterfac
 		},
 		Current: 0,
 	}
+	for i, d := range m.phaseDistrs {
+		if d != nil {
+			req.Phases[i].ServiceTime = d.GetRand()
+		}
+	}
+	return req
+}
+
+// traceDevices maps the device names of request traces to device types
+var traceDevices = map[string]int{
+	"cpu": int(Processor),
+	"acc": int(Accelerator),
+}
+
+// TraceReqCreator creates the MultiPhaseReq of a trace record
+type TraceReqCreator struct{}
+
+// NewTraceRequest returns a new MultiPhaseReq with the phases of rec
+func (TraceReqCreator) NewTraceRequest(now float64, rec blocks.TraceRecord) engine.ReqInterface {
+	req := &MultiPhaseReq{Class: rec.Class}
+	for i, p := range rec.Phases {
+		phase := Phase{
+			Request: blocks.Request{InitTime: -1, ServiceTime: p.Service},
+			Devices: make(map[DeviceType]struct{}),
+		}
+		if i == 0 {
+			phase.InitTime = now
+		}
+		for _, d := range p.Devices {
+			phase.Devices[DeviceType(d)] = struct{}{}
+		}
+		req.Phases = append(req.Phases, phase)
+	}
+	return req
 }
 
-func (m *MultiPhaseReq) GetDelay() float64 {
-	return engine.GetTime() - m.Phases[0].InitTime
+func (m *MultiPhaseReq) GetDelay(now float64) float64 {
+	return now - m.Phases[0].InitTime
 }
 
 func (m *MultiPhaseReq) GetServiceTime() float64 {
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/blocks"
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// delayDrain keeps the latency of every finished request by class
+type delayDrain struct {
+	delays map[int]float64
+}
+
+func (d *delayDrain) TerminateReq(req engine.ReqInterface, now float64) {
+	d.delays[req.(*MultiPhaseReq).Class] = req.GetDelay(now)
+}
+
+func (d *delayDrain) SetName(name string) {}
+
+// replay runs a trace through a GPCore and an AXCore and returns the
+// latency of every request by class
+func replay(t *testing.T, trace string) map[int]float64 {
+	path := filepath.Join(t.TempDir(), "trace.csv")
+	if err := os.WriteFile(path, []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := blocks.LoadTrace(path, traceDevices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := engine.NewSimulation(1)
+	drain := &delayDrain{delays: make(map[int]float64)}
+	g := blocks.NewTraceGenerator(records, TraceReqCreator{}, &blocks.RoundRobinDispatch{})
+
+	gpCore := &GPCore{}
+	gpCore.outboundMax = 4
+	gpCore.queueChooseFunc = firstNonEmptyQueue
+	gpCore.gpCoreForwardFunc = tryAxCoreOutqueueThenFallback
+	gpCore.SetReqDrain(drain)
+	axCore := &AXCore{}
+	axCore.forwardFunc = forwardToCentralized
+	axCore.speedup = 1
+
+	q, postQueue, axQueue := blocks.NewQueue(), blocks.NewQueue(), blocks.NewQueue()
+	axCore.AddOutQueue(postQueue)
+	gpCore.AddInQueue(postQueue)
+	gpCore.AddOutQueue(axQueue)
+	axCore.AddInQueue(axQueue)
+	gpCore.AddInQueue(q)
+	g.AddOutQueue(q)
+
+	sim.RegisterActor(gpCore)
+	sim.RegisterActor(axCore)
+	sim.RegisterActor(g)
+	if err := sim.Run(1000, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(drain.delays) != len(records) {
+		t.Fatalf("finished %v requests, want %v", len(drain.delays), len(records))
+	}
+	return drain.delays
+}
+
+func TestReplayDeviceEligibility(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		// latency of every class, the requests never overlap
+		want map[int]float64
+	}{
+		{
+			name:  "cpu only phases stay on the processor",
+			trace: "0,0,2,cpu,10,cpu\n",
+			want:  map[int]float64{0: 12},
+		},
+		{
+			name:  "accelerator only first phase",
+			trace: "0,0,2,acc,3,cpu\n",
+			want:  map[int]float64{0: 5},
+		},
+		{
+			name:  "accelerator only last phase",
+			trace: "0,0,2,cpu,3,acc\n",
+			want:  map[int]float64{0: 5},
+		},
+		{
+			name:  "mixed phases",
+			trace: "0,0,2,cpu,10,cpu\n100,1,2,acc,3,cpu|acc,1,acc\n200,2,1,cpu|acc,4,cpu\n",
+			want:  map[int]float64{0: 12, 1: 6, 2: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replay(t, tt.trace)
+			for class, want := range tt.want {
+				if got[class] != want {
+					t.Errorf("class %v latency %v, want %v", class, got[class], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/neel-patel-1/xmp_sched_sim/engine"
+)
+
+// captureStdout returns what f prints
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+	f()
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// runTopology runs a topology with the given seed and backend and returns
+// its output
+func runTopology(t *testing.T, topo int, opts simOptions, seed int64, backend engine.Backend) string {
+	opts.seed = seed
+	opts.backend = backend
+	return captureStdout(t, func() {
+		switch topo {
+		case 2:
+			fallback_multi_gpcore_axcore_three_phase(10000, 1, 8, 4, 4, 0.6, 0.1, 2, 0.25, 0.5, 0.25, opts)
+		case 3:
+			multi_gpcore_multi_axcore_multi_centralized(10000, 1, 8, 4, 4, 0.6, 0.1, 0, 0.25, 0.5, 0.25, opts)
+		case 4:
+			multi_gpcore_multi_axcore_prefn_centralized_axfn_centralized_postfn_returntosender(10000, 1, 8, 4, 4, 0.6, 0.1, 3, 0.25, 0.5, 0.25, opts)
+		case 5:
+			multi_gpcore_multi_axcore_three_phase(10000, 1, 8, 4, 4, 0.6, 0.1, 0, 0.25, 0.5, 0.25,
+				forwardToOffloaderThreePhase, tryAxCoreOutqueueThenFallback, firstNonEmptyQueue, opts)
+		}
+	})
+}
+
+func TestSeedReproducibility(t *testing.T) {
+	tests := []struct {
+		name string
+		topo int
+		opts simOptions
+	}{
+		{"fallback", 2, simOptions{}},
+		{"centralized", 3, simOptions{}},
+		{"return to sender", 4, simOptions{}},
+		{"three phase", 5, simOptions{}},
+		{"jsq dispatch", 5, simOptions{dispatch: "jsq"}},
+		{"closed loop", 5, simOptions{clients: 8, arrival: "exp:0.1"}},
+		{"batches", 5, simOptions{batch: "exp:0.5", service: "bimodal:1,20,0.9"}},
+		{"load profile", 3, simOptions{profile: "sin:0.6,0.3,5000,4"}},
+		{"drain", 5, simOptions{drain: true, usage: true, queues: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := runTopology(t, tt.topo, tt.opts, 1, engine.GoroutineBackend)
+			if got := runTopology(t, tt.topo, tt.opts, 1, engine.GoroutineBackend); got != want {
+				t.Errorf("same seed, different output:\n%v\nwant:\n%v", got, want)
+			}
+			if got := runTopology(t, tt.topo, tt.opts, 1, engine.EventLoopBackend); got != want {
+				t.Errorf("event loop backend output:\n%v\nwant the goroutine backend output:\n%v", got, want)
+			}
+			if got := runTopology(t, tt.topo, tt.opts, 2, engine.GoroutineBackend); got == want {
+				t.Error("different seeds, same output")
+			}
+		})
+	}
+}
@@ -17,7 +17,7 @@ metrics = ['Count', 'Stolen', 'AVG', 'STDDev',
 
 
 def run(num_cores, num_accelerators, bufferSize, mu, gen_type, phase_one_ratio, phase_two_ratio, phase_three_ratio, speedup,
-        gpcore_offload_style, axcore_notify_recipient, gpcore_input_queue_selector, duration, name):
+        gpcore_offload_style, axcore_notify_recipient, gpcore_input_queue_selector, duration, name, warmup=0):
     '''
     mu in us
     '''
@@ -38,7 +38,7 @@ def run(num_cores, num_accelerators, bufferSize, mu, gen_type, phase_one_ratio,
                 f"--phase_three_ratio={phase_three_ratio} --speedup={speedup} "
                 f"--lambda={l} --num_cores={num_cores} --num_accelerators={num_accelerators} "
                 f"--buffersize={bufferSize} "
-                f"--duration={duration} "
+                f"--duration={duration} --warmup={warmup} "
                 f"--gpcore_offload_style={gpcore_offload_style} "
                 f"--axcore_notify_recipient={axcore_notify_recipient} "
                 f"--gpcore_input_queue_selector={gpcore_input_queue_selector}"
@@ -67,7 +67,7 @@ def run(num_cores, num_accelerators, bufferSize, mu, gen_type, phase_one_ratio,
                     f"--phase_three_ratio={phase_three_ratio} --speedup={speedup} "
                     f"--lambda={l + 1.0} --num_cores={num_cores} --num_accelerators={num_accelerators} "
                     f"--buffersize={bufferSize} "
-                    f"--duration={duration} "
+                    f"--duration={duration} --warmup={warmup} "
                     f"--gpcore_offload_style={gpcore_offload_style} "
                     f"--axcore_notify_recipient={axcore_notify_recipient} "
                     f"--gpcore_input_queue_selector={gpcore_input_queue_selector}"
@@ -10,27 +10,22 @@ import (
 
 func fallback_multi_gpcore_axcore_three_phase(duration float64, speedup float64,
 	num_cores int, num_accelerators int, axCoreQueueSize int, lambda, mu float64, genType int,
-	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64) {
+	phase_one_ratio float64, phase_two_ratio float64, phase_three_ratio float64, opts simOptions) {
 
-	engine.InitSim()
+	sim := opts.newSimulation()
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
-
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
-	}
-	g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio})
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
+
+	g, drain := opts.newGenerator(sim, stats, &ThreePhaseReqCreator{phase_one_ratio: phase_one_ratio, phase_two_ratio: phase_two_ratio, phase_three_ratio: phase_three_ratio}, genType, lambda, mu)
 	q := blocks.NewQueue()
+	q.SetLabel("arrival_q")
 	g.AddOutQueue(q)
 
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q)
+
 	// determine how many gpCores to an axCore
 	num_gpCores := num_cores / num_accelerators
 	if num_cores%num_accelerators != 0 {
@@ -40,6 +35,8 @@ func fallback_multi_gpcore_axcore_three_phase(duration float64, speedup float64,
 	num_clusters := num_accelerators
 	for i := 0; i < num_clusters; i++ {
 		axQueue := blocks.NewQueue()
+		axQueue.SetLabel(fmt.Sprintf("ax_q %d", i))
+		sampler.WatchQueue(axQueue)
 
 		//create axCore
 		axCore := &AXCore{}
@@ -49,6 +46,8 @@ func fallback_multi_gpcore_axcore_three_phase(duration float64, speedup float64,
 
 		for j := 0; j < num_gpCores; j++ {
 			postQueue := blocks.NewQueue()
+			postQueue.SetLabel(fmt.Sprintf("post_q %d.%d", i, j))
+			sampler.WatchQueue(postQueue)
 
 			// create gpCore
 			gpCore := &GPCore{}
@@ -61,31 +60,35 @@ func fallback_multi_gpcore_axcore_three_phase(duration float64, speedup float64,
 			axCore.AddOutQueue(postQueue)
 			gpCore.gpCoreIdx = j
 			gpCore.AddInQueue(postQueue)
-			gpCore.SetReqDrain(stats)
+			gpCore.SetReqDrain(drain)
 
 			gpCore.AddOutQueue(axQueue)
 
 			gpCore.AddInQueue(q)
 
-			engine.RegisterActor(gpCore)
+			sim.RegisterActor(gpCore)
+			sampler.WatchActor(fmt.Sprintf("gpcore %d.%d", i, j), gpCore)
 		}
 
-		engine.RegisterActor(axCore)
+		sim.RegisterActor(axCore)
+		sampler.WatchActor(fmt.Sprintf("axcore %d", i), axCore)
 	}
 
-	engine.RegisterActor(g)
+	g.register(sim)
+	opts.addSampler(sim, sampler)
 
 	fmt.Printf("Cores:%d\tAccelerators:%d\tMu:%f\tLambda:%f\taxCoreQueueSize:%d\taxCoreSpeedup:%f\tgenType:%d\tphase_one_ratio:%f\tphase_two_ratio:%f\tphase_three_ratio:%f\n", num_cores, num_accelerators, mu, lambda, axCoreQueueSize, speedup, genType, phase_one_ratio, phase_two_ratio, phase_three_ratio)
-	engine.Run(duration)
+	opts.run(sim, duration)
 
 }
 
-func single_core_deterministic(interarrival_time, service_time, duration float64) {
-	engine.InitSim()
+func single_core_deterministic(interarrival_time, service_time, duration float64, opts simOptions) {
+	sim := opts.newSimulation()
 
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
 
 	// Add generator
 	g := blocks.NewDDGenerator(interarrival_time, service_time)
@@ -98,30 +101,46 @@ func single_core_deterministic(interarrival_time, service_time, duration float64
 	p := &blocks.RTCProcessor{}
 	p.AddInQueue(q)
 	p.SetReqDrain(stats)
-	engine.RegisterActor(p)
+	sim.RegisterActor(p)
 
 	g.AddOutQueue(q)
 
 	// Register the generator
-	engine.RegisterActor(g)
+	sim.RegisterActor(g)
+
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q)
+	sampler.WatchActor("core", p)
+	opts.addSampler(sim, sampler)
 
 	fmt.Printf("Cores:%v\tservice_time:%v\tinterarrival_rate:%v\n", 1, service_time, interarrival_time)
-	engine.Run(duration)
+	opts.run(sim, duration)
 }
 
-func chained_cores_multi_phase_deterministic(interarrival_time, service_time, duration float64, speedup float64) {
-	engine.InitSim()
+func chained_cores_multi_phase_deterministic(interarrival_time, service_time, duration float64, speedup float64, opts simOptions) {
+	sim := opts.newSimulation()
 
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
+
+	opts.checkFixedGenerator()
+	if opts.phases != [3]string{} {
+		log.Fatal("Error: --phase_*_service need three phase requests")
+	}
 
 	// Add generator
 	g := blocks.NewDDGenerator(interarrival_time, service_time)
 	g.SetCreator(&MultiPhaseReqCreator{})
+	if err := opts.setBudget(g); err != nil {
+		log.Fatal(err)
+	}
 
 	q := blocks.NewQueue()
+	q.SetLabel("arrival_q")
 	q2 := blocks.NewQueue()
+	q2.SetLabel("ax_q")
 
 	// Create processors
 	p := &RTCMPProcessor{}
@@ -143,29 +162,42 @@ func chained_cores_multi_phase_deterministic(interarrival_time, service_time, du
 	p.forwardFunc = func(outQueues []engine.QueueInterface, req *MultiPhaseReq) int {
 		return 0
 	}
-	engine.RegisterActor(p)
+	sim.RegisterActor(p)
 
-	engine.RegisterActor(p2)
+	sim.RegisterActor(p2)
 
 	g.AddOutQueue(q)
 
-	engine.RegisterActor(g)
-	engine.Run(duration)
+	sim.RegisterActor(g)
+
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q, q2)
+	sampler.WatchActor("core", p)
+	sampler.WatchActor("accelerator", p2)
+	opts.addSampler(sim, sampler)
+	opts.run(sim, duration)
 }
 
 func fallback_gpcore_core_three_phase_single(interarrival_time, service_time, duration float64, speedup float64,
-	num_cores int, num_accelerators int, axCoreQueueSize int) {
-	engine.InitSim()
+	num_cores int, num_accelerators int, axCoreQueueSize int, opts simOptions) {
+	sim := opts.newSimulation()
 
 	stats := &blocks.AllKeeper{}
 	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	sim.InitStats(stats)
+	opts.initStats(sim, stats)
 
 	// Add generator && set up dispatcher
+	opts.checkFixedGenerator()
 	g := blocks.NewDDGenerator(interarrival_time, service_time)
 	// g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: 0.1, phase_two_ratio: 0.6, phase_three_ratio: 0.3}) // Update-Filter-Histogram-1KB
-	g.SetCreator(&ThreePhaseReqCreator{phase_one_ratio: 0.25, phase_two_ratio: 0.5, phase_three_ratio: 0.25}) // dummy for testing
-	q := blocks.NewQueue()                                                                                    // arrival queue
+	creator := &ThreePhaseReqCreator{phase_one_ratio: 0.25, phase_two_ratio: 0.5, phase_three_ratio: 0.25} // dummy for testing
+	g.SetCreator(opts.phaseCreator(creator))
+	if err := opts.setBudget(g); err != nil {
+		log.Fatal(err)
+	}
+	q := blocks.NewQueue() // arrival queue
+	q.SetLabel("arrival_q")
 
 	// create gpCore
 	gpCore := &GPCore{}
@@ -180,6 +212,7 @@ func fallback_gpcore_core_three_phase_single(interarrival_time, service_time, du
 
 	// link post-processing queue of gpCore to output of axCore
 	postQueue := blocks.NewQueue()
+	postQueue.SetLabel("post_q")
 	// add this one first -- highest priority
 	axCore.AddOutQueue(postQueue)
 	gpCore.gpCoreIdx = 0         // indicates the outgoing queue index to use to re-enqueue at this gpCore
@@ -187,18 +220,25 @@ func fallback_gpcore_core_three_phase_single(interarrival_time, service_time, du
 	gpCore.SetReqDrain(stats)
 
 	axQueue := blocks.NewQueue()
+	axQueue.SetLabel("ax_q")
 	gpCore.AddOutQueue(axQueue) // axCore input queue (produced by gpCore)
 	axCore.AddInQueue(axQueue)  // axCore input queue (produced by gpCore)
 
 	gpCore.AddInQueue(q) // pre-processing queue (produced by load gen)
 
-	engine.RegisterActor(gpCore)
-	engine.RegisterActor(axCore)
+	sim.RegisterActor(gpCore)
+	sim.RegisterActor(axCore)
 
 	g.AddOutQueue(q)
-	engine.RegisterActor(g)
+	sim.RegisterActor(g)
+
+	sampler := opts.newSampler(stats)
+	sampler.WatchQueue(q, axQueue, postQueue)
+	sampler.WatchActor("gpcore", gpCore)
+	sampler.WatchActor("axcore", axCore)
+	opts.addSampler(sim, sampler)
 
 	// create an in queue used by the axCore to re-enqueue the third phase back at the GPCore
 
-	engine.Run(duration)
+	opts.run(sim, duration)
 }